@@ -0,0 +1,265 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pointlander/gradient/tf32"
+)
+
+// Activation is the activation function of a layer
+type Activation int
+
+const (
+	// ActivationDefault is sigmoid for hidden layers and the activation of the dataset for the output layer
+	ActivationDefault Activation = iota
+	// ActivationSigmoid is 1/(1+e^-x)
+	ActivationSigmoid
+	// ActivationTanH is the hyperbolic tangent
+	ActivationTanH
+	// ActivationReLU is max(0, x)
+	ActivationReLU
+	// ActivationLeakyReLU is max(.01x, x)
+	ActivationLeakyReLU
+	// ActivationSoftplus is log(1+e^x)
+	ActivationSoftplus
+	// ActivationGELU is the tanh approximation of the gaussian error linear unit
+	ActivationGELU
+	// ActivationIdentity is x
+	ActivationIdentity
+	// ActivationSoftmax is the softmax function with the full jacobian
+	ActivationSoftmax
+)
+
+// ActivationFunctions the activation functions
+var ActivationFunctions = [...]Activation{
+	ActivationDefault,
+	ActivationSigmoid,
+	ActivationTanH,
+	ActivationReLU,
+	ActivationLeakyReLU,
+	ActivationSoftplus,
+	ActivationGELU,
+	ActivationIdentity,
+	ActivationSoftmax,
+}
+
+// Converts the activation to a string
+func (a Activation) String() string {
+	switch a {
+	case ActivationDefault:
+		return "default"
+	case ActivationSigmoid:
+		return "sigmoid"
+	case ActivationTanH:
+		return "tanh"
+	case ActivationReLU:
+		return "relu"
+	case ActivationLeakyReLU:
+		return "leakyrelu"
+	case ActivationSoftplus:
+		return "softplus"
+	case ActivationGELU:
+		return "gelu"
+	case ActivationIdentity:
+		return "identity"
+	case ActivationSoftmax:
+		return "softmax"
+	}
+	return "unknown"
+}
+
+// Set sets the activation from its name
+func (a *Activation) Set(name string) error {
+	for _, activation := range ActivationFunctions {
+		if activation.String() == name {
+			*a = activation
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown activation %s", name)
+}
+
+// MarshalText converts the activation to its name
+func (a Activation) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText sets the activation from its name
+func (a *Activation) UnmarshalText(text []byte) error {
+	return a.Set(string(text))
+}
+
+// Apply applies the activation function with the training operators, the default is sigmoid
+func (a Activation) Apply(m tf32.Meta) tf32.Meta {
+	return a.ApplyWith(Training, m)
+}
+
+// ApplyWith applies the activation function with the given operators, the default is sigmoid
+func (a Activation) ApplyWith(o *Operators, m tf32.Meta) tf32.Meta {
+	switch a {
+	case ActivationTanH:
+		return o.TanH(m)
+	case ActivationReLU:
+		return o.ReLU(m)
+	case ActivationLeakyReLU:
+		return o.LeakyReLU(m)
+	case ActivationSoftplus:
+		return o.Softplus(m)
+	case ActivationGELU:
+		return o.GELU(m)
+	case ActivationIdentity:
+		return m
+	case ActivationSoftmax:
+		return o.Softmax(m)
+	}
+	return o.Sigmoid(m)
+}
+
+// Activations are the activation functions of each layer
+type Activations []Activation
+
+// Layer is the activation function of a layer, the default if there isn't one
+func (a Activations) Layer(layer int) Activation {
+	if layer < len(a) {
+		return a[layer]
+	}
+	return ActivationDefault
+}
+
+// Set sets the activations from a comma separated list of names
+func (a *Activations) Set(list string) error {
+	activations := Activations{}
+	for _, name := range strings.Split(list, ",") {
+		var activation Activation
+		err := activation.Set(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		activations = append(activations, activation)
+	}
+	*a = activations
+	return nil
+}
+
+// Converts the activations to a comma separated list of names
+func (a *Activations) String() string {
+	names := make([]string, len(*a))
+	for i, activation := range *a {
+		names[i] = activation.String()
+	}
+	return strings.Join(names, ",")
+}
+
+// unary creates an element wise operator of a context from a function and its derivative
+// The derivative is given the input x and the output y of the function
+func unary(context *tf32.Context, f func(x float32) float32, df func(x, y float32) float32) func(a tf32.Meta) tf32.Meta {
+	return tf32.U(func(a *tf32.V) func(k tf32.Continuation) {
+		return func(k tf32.Continuation) {
+			c := tf32.NewV(a.S...)
+			for _, x := range a.X {
+				c.X = append(c.X, f(x))
+			}
+			k(&c)
+			if context.InferenceOnly {
+				return
+			}
+			for i, d := range c.D {
+				a.D[i] += d * df(a.X[i], c.X[i])
+			}
+		}
+	})
+}
+
+func exp(x float32) float32 {
+	return float32(math.Exp(float64(x)))
+}
+
+func tanh(x float32) float32 {
+	return float32(math.Tanh(float64(x)))
+}
+
+// gelu is sqrt(2/pi) for the tanh approximation of gelu
+var gelu = float32(math.Sqrt(2 / math.Pi))
+
+// activations creates the activation operators
+func (o *Operators) activations() {
+	o.ReLU = unary(o.Context, func(x float32) float32 {
+		if x > 0 {
+			return x
+		}
+		return 0
+	}, func(x, y float32) float32 {
+		if x > 0 {
+			return 1
+		}
+		return 0
+	})
+	o.LeakyReLU = unary(o.Context, func(x float32) float32 {
+		if x > 0 {
+			return x
+		}
+		return .01 * x
+	}, func(x, y float32) float32 {
+		if x > 0 {
+			return 1
+		}
+		return .01
+	})
+	o.Softplus = unary(o.Context, func(x float32) float32 {
+		// e^x overflows for large x where log(1+e^x) is x
+		if x > 20 {
+			return x
+		}
+		return float32(math.Log1p(math.Exp(float64(x))))
+	}, func(x, y float32) float32 {
+		return 1 / (1 + exp(-x))
+	})
+	o.GELU = unary(o.Context, func(x float32) float32 {
+		return .5 * x * (1 + tanh(gelu*(x+.044715*x*x*x)))
+	}, func(x, y float32) float32 {
+		t := tanh(gelu * (x + .044715*x*x*x))
+		return .5*(1+t) + .5*x*(1-t*t)*gelu*(1+3*.044715*x*x)
+	})
+	// Unlike tf32.Softmax it is stable for large inputs and its derivative includes the cross terms of the jacobian
+	o.Softmax = tf32.U(func(a *tf32.V) func(k tf32.Continuation) {
+		return func(k tf32.Continuation) {
+			c, size, width := tf32.NewV(a.S...), len(a.X), a.S[0]
+			for i := 0; i < size; i += width {
+				max := a.X[i]
+				for _, ax := range a.X[i : i+width] {
+					if ax > max {
+						max = ax
+					}
+				}
+				sum := float32(0.0)
+				for _, ax := range a.X[i : i+width] {
+					e := exp(ax - max)
+					sum += e
+					c.X = append(c.X, e)
+				}
+				for j, cx := range c.X[i : i+width] {
+					c.X[i+j] = cx / sum
+				}
+			}
+			k(&c)
+			if o.Context.InferenceOnly {
+				return
+			}
+			for i := 0; i < size; i += width {
+				cx, cd, dot := c.X[i:i+width], c.D[i:i+width], float32(0.0)
+				for j, d := range cd {
+					dot += d * cx[j]
+				}
+				for j, d := range cd {
+					a.D[i+j] += cx[j] * (d - dot)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"reflect"
+)
+
+// CheckpointVersion is the version of the checkpoint format
+const CheckpointVersion = 1
+
+// CountingSource is a random source that counts its draws so that its position can be restored
+type CountingSource struct {
+	rand.Source64
+	Draws uint64
+}
+
+// NewCountingSource creates a new counting source
+func NewCountingSource(seed int64) *CountingSource {
+	return &CountingSource{
+		Source64: rand.NewSource(seed).(rand.Source64),
+	}
+}
+
+// Int63 returns a random int64
+func (s *CountingSource) Int63() int64 {
+	s.Draws++
+	return s.Source64.Int63()
+}
+
+// Uint64 returns a random uint64
+func (s *CountingSource) Uint64() uint64 {
+	s.Draws++
+	return s.Source64.Uint64()
+}
+
+// Skip advances the source to draws
+func (s *CountingSource) Skip(draws uint64) {
+	for s.Draws < draws {
+		s.Int63()
+	}
+}
+
+// Checkpoint is the state of a training run
+// It is stored in the gob format which unlike json can hold the NaNs of a diverged run
+type Checkpoint struct {
+	Version   int
+	Dataset   string
+	Config    Config
+	Seed      int64
+	Optimizer OptimizerType
+	Mode      Mode
+	Batch     bool
+	Context   bool
+	// Epoch is the number of completed epochs
+	Epoch     int
+	Converged bool
+	// Draws is the position of the random number generator used for shuffling
+	Draws      uint64
+	Parameters [][]float32
+	// Optimizers is the optimizer state followed by the state of each per datum optimizer
+	Optimizers [][]byte
+	// Order is the order of the shuffled training data
+	Order           []int
+	Costs           []float32
+	ValidationCosts []float32
+}
+
+// Name is the file name of the checkpoint
+func (c *Checkpoint) Name() string {
+	return fmt.Sprintf("%s_%s_%s_%t_%t_%d.gob", c.Dataset, c.Mode, c.Optimizer, c.Batch, c.Context, c.Seed)
+}
+
+// Matches checks if the checkpoint is for the same training run as other
+// The number of seeds and the maximum number of epochs can change between runs
+func (c *Checkpoint) Matches(other *Checkpoint) bool {
+	a, b := c.Config, other.Config
+	a.Seeds, b.Seeds, a.Epochs, b.Epochs = 0, 0, 0, 0
+	return c.Version == other.Version && c.Dataset == other.Dataset && c.Seed == other.Seed &&
+		c.Optimizer == other.Optimizer && c.Mode == other.Mode && c.Batch == other.Batch &&
+		c.Context == other.Context && reflect.DeepEqual(a, b)
+}
+
+// Store stores the state of the optimizers in the checkpoint
+func (c *Checkpoint) Store(optimizers ...Optimizer) error {
+	c.Optimizers = make([][]byte, len(optimizers))
+	for i, optimizer := range optimizers {
+		buffer := bytes.Buffer{}
+		err := gob.NewEncoder(&buffer).Encode(optimizer)
+		if err != nil {
+			return err
+		}
+		c.Optimizers[i] = buffer.Bytes()
+	}
+	return nil
+}
+
+// Restore restores the optimizers from the checkpoint
+func (c *Checkpoint) Restore() ([]Optimizer, error) {
+	optimizers := make([]Optimizer, len(c.Optimizers))
+	for i, data := range c.Optimizers {
+		optimizers[i] = c.Optimizer.New(c.Config.Hyperparameters)
+		err := gob.NewDecoder(bytes.NewReader(data)).Decode(optimizers[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return optimizers, nil
+}
+
+// Save writes the checkpoint to a file
+func (c *Checkpoint) Save(name string) error {
+	buffer := bytes.Buffer{}
+	err := gob.NewEncoder(&buffer).Encode(c)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(name+".tmp", buffer.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
+
+// LoadCheckpoint reads a checkpoint from a file
+func LoadCheckpoint(name string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := Checkpoint{}
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint.Version != CheckpointVersion {
+		return nil, fmt.Errorf("checkpoint version %d is not %d", checkpoint.Version, CheckpointVersion)
+	}
+	return &checkpoint, nil
+}
+
+// Network rebuilds the trained network from the checkpoint for inference
+func (c *Checkpoint) Network() (*Network, error) {
+	dataset, ok := Datasets[c.Dataset]
+	if !ok {
+		return nil, fmt.Errorf("unknown dataset %s", c.Dataset)
+	}
+	data := dataset.Data()
+	network := NewNetwork(nil, dataset, c.Config, c.Mode, len(data[0].Input), len(data[0].Output), 1)
+	if len(network.Parameters) != len(c.Parameters) {
+		return nil, fmt.Errorf("checkpoint has %d parameters and the network has %d", len(c.Parameters), len(network.Parameters))
+	}
+	for i, p := range network.Parameters {
+		if len(p.X) != len(c.Parameters[i]) {
+			return nil, fmt.Errorf("checkpoint parameter %d has the wrong size", i)
+		}
+		copy(p.X, c.Parameters[i])
+	}
+	return network, nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/pointlander/gradient/tf32"
+)
+
+// Compress evaluates the weight expressions of a trained network down to plain matrices
+// For an inception network each (A1*A2 + A3) expression becomes a single matrix A
+func Compress(weights ...tf32.Meta) []tf32.V {
+	compressed := make([]tf32.V, len(weights))
+	for i, weight := range weights {
+		weight(func(a *tf32.V) {
+			compressed[i] = tf32.NewV(a.S...)
+			compressed[i].Set(a.X)
+		})
+	}
+	return compressed
+}
+
+// Dense computes the output of the network for an input with compressed weights
+// The weights are multiplied with plain loops, so it checks the compression independently of the tf32 expressions
+func (n *Network) Dense(weights []tf32.V, input []float32) []float32 {
+	layer, layers := input, len(weights)/2
+	for i := 0; i < len(weights); i += 2 {
+		w, b := weights[i], weights[i+1]
+		// each row of the weight matrix is the weights of a neuron
+		sums := tf32.NewV(w.S[1])
+		for j := 0; j < w.S[1]; j++ {
+			sum := b.X[j]
+			for k, value := range layer {
+				sum += w.X[j*w.S[0]+k] * value
+			}
+			sums.X = append(sums.X, sum)
+		}
+		n.Activate(Inference, i/2, layers, sums.Meta())(func(a *tf32.V) {
+			layer = append([]float32{}, a.X...)
+		})
+	}
+	return layer
+}
+
+// Equal checks if two outputs are the same within a tolerance
+func Equal(a, b []float32, tolerance float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, value := range a {
+		diff := value - b[i]
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > tolerance {
+			return false
+		}
+	}
+	return true
+}
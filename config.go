@@ -0,0 +1,392 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Config is the configuration of an experiment
+type Config struct {
+	// Width is the number of hidden neurons
+	Width int `json:"width"`
+	// Depth is the number of factor pairs for each inception weight
+	Depth int `json:"depth"`
+	// Layers are the numbers of hidden neurons of each hidden layer, one layer of Width if empty
+	Layers []int `json:"layers"`
+	// Factorized are the indexes of the layers with inception or dct weights, all of the layers if empty
+	Factorized []int `json:"factorized"`
+	// Activations are the activation functions of each layer, the default for the layers without one
+	Activations Activations `json:"activations"`
+	// WeightInit is the initializer of the weights
+	WeightInit Initializer `json:"weight_init"`
+	// BiasInit is the initializer of the biases
+	BiasInit Initializer `json:"bias_init"`
+	// FactorAInit is the initializer of the first factor A1 of each inception factor pair
+	FactorAInit Initializer `json:"factor_a_init"`
+	// FactorBInit is the initializer of the second factor A2 of each inception factor pair
+	// With zeros the inception network starts out as the same function as the normal network
+	FactorBInit Initializer `json:"factor_b_init"`
+	// InitScale is the scale of the uniform, orthogonal and normal initializers, 1 if it is 0
+	InitScale float32 `json:"init_scale"`
+	// BatchSize is the number of datums in a batch
+	BatchSize int `json:"batch_size"`
+	// Epochs is the maximum number of epochs
+	Epochs int `json:"epochs"`
+	// Seeds is the number of seeds of a repeated experiment
+	Seeds int `json:"seeds"`
+	Hyperparameters
+	// Genetic is the genetic algorithm of the parallel experiments
+	Genetic Genetic `json:"genetic"`
+	// Clip clips the norm of the gradient to 1
+	Clip bool `json:"clip"`
+	// Loss is the cost function, the loss of the dataset by default
+	Loss Loss `json:"loss"`
+	// Criterion is the method for deciding if training has converged
+	Criterion Criterion `json:"criterion"`
+	// LossThreshold replaces the threshold of the loss for the mean criterion if it isn't 0
+	LossThreshold float32 `json:"loss_threshold"`
+	// Threshold is the epoch cost below which training has converged
+	Threshold float32 `json:"threshold"`
+	// BatchThreshold is the threshold when batching, the threshold divided by the batch size if it is 0
+	BatchThreshold float32 `json:"batch_threshold"`
+	// AdamThreshold is the threshold without batching for the optimizers with the small learning rate Rate:
+	// rmsprop, adam, adamw and amsgrad
+	AdamThreshold float32 `json:"adam_threshold"`
+	// Validation is the fraction of each class held out for validation
+	Validation float64 `json:"validation"`
+	// Test is the fraction of each class held out for testing
+	Test float64 `json:"test"`
+}
+
+// Converged checks if the total cost of an epoch of batches is below the threshold
+func (c *Config) Converged(optimizer OptimizerType, batch bool, total float32, batches int, loss Loss) bool {
+	if c.Criterion == CriterionMean {
+		threshold := c.LossThreshold
+		if threshold == 0 {
+			threshold = loss.Threshold()
+		}
+		return total/float32(batches) < threshold
+	}
+	if batch {
+		return total < c.Batched()
+	}
+	if optimizer.UsesRate() {
+		return total < c.AdamThreshold
+	}
+	return total < c.Threshold
+}
+
+// Batched is the threshold when batching
+func (c *Config) Batched() float32 {
+	if c.BatchThreshold == 0 && c.BatchSize > 0 {
+		return c.Threshold / float32(c.BatchSize)
+	}
+	return c.BatchThreshold
+}
+
+// Validate checks the configuration
+func (c *Config) Validate() error {
+	err := c.Schedule.Check(c.Hyperparameters)
+	if err != nil {
+		return err
+	}
+	err = CheckFractions(c.Validation, c.Test)
+	if err != nil {
+		return err
+	}
+	// the genetic algorithm is only checked if it is configured
+	if c.Genetic != (Genetic{}) {
+		return c.CheckGenetic()
+	}
+	return nil
+}
+
+// CheckGenetic checks the configuration of the genetic algorithm
+func (c *Config) CheckGenetic() error {
+	g := c.Genetic
+	if g.Population < 2 {
+		return fmt.Errorf("the population %d should have at least 2 individuals", g.Population)
+	}
+	if g.Elitism < 0 || g.Elitism >= g.Population {
+		return fmt.Errorf("the elitism %d should be at least 0 and less than the population %d", g.Elitism, g.Population)
+	}
+	if g.Islands <= 0 {
+		return fmt.Errorf("the number of islands %d should be positive", g.Islands)
+	}
+	// the migrants are the best individuals of an island and they don't replace the elites of their neighbors
+	if g.Migrants < 0 || g.Migrants > g.Elitism {
+		return fmt.Errorf("the migrants %d should be at least 0 and no more than the elitism %d", g.Migrants, g.Elitism)
+	}
+	if c.Genetic.UsesGenome() {
+		factorized := false
+		for i := 0; i <= len(c.Widths()); i++ {
+			factorized = factorized || c.IsFactorized(i)
+		}
+		if c.Depth <= 0 || !factorized {
+			return fmt.Errorf("the %s crossover and %s mutation need a depth above 0 and a factorized layer for the genome",
+				c.Genetic.Crossover, c.Genetic.Mutation)
+		}
+	}
+	return nil
+}
+
+// GeneticConverged checks if the cost of the best network of the genetic algorithm is below the threshold
+// The networks of the genetic algorithm train with gradient descent in batches
+func (c *Config) GeneticConverged(cost float32, batches int, loss Loss) bool {
+	return c.Converged(OptimizerStatic, true, cost, batches, loss)
+}
+
+// Scale is the scale of the uniform, orthogonal and normal initializers
+func (c *Config) Scale() float32 {
+	if c.InitScale == 0 {
+		return 1
+	}
+	return c.InitScale
+}
+
+// Widths are the numbers of hidden neurons of the hidden layers
+func (c *Config) Widths() []int {
+	if len(c.Layers) == 0 {
+		return []int{c.Width}
+	}
+	return c.Layers
+}
+
+// IsFactorized checks if a layer has inception or dct weights
+func (c *Config) IsFactorized(layer int) bool {
+	if len(c.Factorized) == 0 {
+		return true
+	}
+	for _, factorized := range c.Factorized {
+		if factorized == layer {
+			return true
+		}
+	}
+	return false
+}
+
+// Load overrides the configuration with the values in a json file
+func (c *Config) Load(name string) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, c)
+}
+
+// Override overrides the configuration with the command line flags that were set
+func (c *Config) Override(flags *Config) {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "width":
+			c.Width = flags.Width
+		case "depth":
+			c.Depth = flags.Depth
+		case "layers":
+			c.Layers = flags.Layers
+		case "factorized":
+			c.Factorized = flags.Factorized
+		case "activations":
+			c.Activations = flags.Activations
+		case "weight-init":
+			c.WeightInit = flags.WeightInit
+		case "bias-init":
+			c.BiasInit = flags.BiasInit
+		case "factor-a-init":
+			c.FactorAInit = flags.FactorAInit
+		case "factor-b-init":
+			c.FactorBInit = flags.FactorBInit
+		case "init-scale":
+			c.InitScale = flags.InitScale
+		case "batch-size":
+			c.BatchSize = flags.BatchSize
+		case "epochs":
+			c.Epochs = flags.Epochs
+		case "seeds":
+			c.Seeds = flags.Seeds
+		case "eta":
+			c.Eta = flags.Eta
+		case "alpha":
+			c.Alpha = flags.Alpha
+		case "rate":
+			c.Rate = flags.Rate
+		case "beta1":
+			c.Beta1 = flags.Beta1
+		case "beta2":
+			c.Beta2 = flags.Beta2
+		case "epsilon":
+			c.Epsilon = flags.Epsilon
+		case "rho":
+			c.Rho = flags.Rho
+		case "decay":
+			c.Decay = flags.Decay
+		case "schedule":
+			c.Schedule = flags.Schedule
+		case "warmup":
+			c.Warmup = flags.Warmup
+		case "gamma":
+			c.Gamma = flags.Gamma
+		case "step-size":
+			c.StepSize = flags.StepSize
+		case "period":
+			c.Period = flags.Period
+		case "period-mult":
+			c.PeriodMult = flags.PeriodMult
+		case "min-rate":
+			c.MinRate = flags.MinRate
+		case "patience":
+			c.Patience = flags.Patience
+		case "population":
+			c.Genetic.Population = flags.Genetic.Population
+		case "elitism":
+			c.Genetic.Elitism = flags.Genetic.Elitism
+		case "selection":
+			c.Genetic.Selection = flags.Genetic.Selection
+		case "parents":
+			c.Genetic.Parents = flags.Genetic.Parents
+		case "tournament":
+			c.Genetic.Tournament = flags.Genetic.Tournament
+		case "crossover":
+			c.Genetic.Crossover = flags.Genetic.Crossover
+		case "crossover-rate":
+			c.Genetic.CrossoverRate = flags.Genetic.CrossoverRate
+		case "generations":
+			c.Genetic.Generations = flags.Genetic.Generations
+		case "mutation":
+			c.Genetic.Mutation = flags.Genetic.Mutation
+		case "sigma":
+			c.Genetic.Sigma = flags.Genetic.Sigma
+		case "islands":
+			c.Genetic.Islands = flags.Genetic.Islands
+		case "topology":
+			c.Genetic.Topology = flags.Genetic.Topology
+		case "migration-interval":
+			c.Genetic.Interval = flags.Genetic.Interval
+		case "migrants":
+			c.Genetic.Migrants = flags.Genetic.Migrants
+		case "clip":
+			c.Clip = flags.Clip
+		case "loss":
+			c.Loss = flags.Loss
+		case "criterion":
+			c.Criterion = flags.Criterion
+		case "loss-threshold":
+			c.LossThreshold = flags.LossThreshold
+		case "threshold":
+			c.Threshold = flags.Threshold
+		case "batch-threshold":
+			c.BatchThreshold = flags.BatchThreshold
+		case "adam-threshold":
+			c.AdamThreshold = flags.AdamThreshold
+		case "validation":
+			c.Validation = flags.Validation
+		case "test":
+			c.Test = flags.Test
+		}
+	})
+}
+
+// float32Value is a flag for a float32
+type float32Value float32
+
+func (f *float32Value) Set(s string) error {
+	value, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return err
+	}
+	*f = float32Value(value)
+	return nil
+}
+
+func (f *float32Value) String() string {
+	return strconv.FormatFloat(float64(*f), 'g', -1, 32)
+}
+
+// intsValue is a flag for a comma separated list of ints
+type intsValue []int
+
+func (i *intsValue) Set(s string) error {
+	values := []int{}
+	for _, field := range strings.Split(s, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return err
+		}
+		values = append(values, value)
+	}
+	*i = values
+	return nil
+}
+
+func (i *intsValue) String() string {
+	fields := make([]string, len(*i))
+	for j, value := range *i {
+		fields[j] = strconv.Itoa(value)
+	}
+	return strings.Join(fields, ",")
+}
+
+// Flags registers the command line flags for the configuration
+func (c *Config) Flags() {
+	flag.IntVar(&c.Width, "width", 0, "the number of hidden neurons")
+	flag.IntVar(&c.Depth, "depth", 0, "the number of factor pairs for each inception weight")
+	flag.Var((*intsValue)(&c.Layers), "layers", "comma separated numbers of hidden neurons of each hidden layer")
+	flag.Var((*intsValue)(&c.Factorized), "factorized", "comma separated indexes of the layers with inception or dct weights")
+	initializers := "uniform, xavier, he, lecun, orthogonal, normal, zeros"
+	flag.Var(&c.WeightInit, "weight-init", "the initializer of the weights: "+initializers)
+	flag.Var(&c.BiasInit, "bias-init", "the initializer of the biases: "+initializers)
+	flag.Var(&c.FactorAInit, "factor-a-init", "the initializer of the first inception factors: "+initializers)
+	flag.Var(&c.FactorBInit, "factor-b-init", "the initializer of the second inception factors, zeros starts as the normal network: "+initializers)
+	flag.Var((*float32Value)(&c.InitScale), "init-scale", "the scale of the uniform, orthogonal and normal initializers")
+	flag.Var(&c.Activations, "activations", "comma separated activation functions of each layer: default, sigmoid, tanh, relu, leakyrelu, softplus, gelu, identity, softmax")
+	flag.IntVar(&c.BatchSize, "batch-size", 0, "the number of datums in a batch")
+	flag.IntVar(&c.Epochs, "epochs", 0, "the maximum number of epochs")
+	flag.IntVar(&c.Seeds, "seeds", 0, "the number of seeds of a repeated experiment")
+	flag.Var((*float32Value)(&c.Eta), "eta", "the learning rate of static, momentum, nesterov and adagrad")
+	flag.Var((*float32Value)(&c.Alpha), "alpha", "the momentum of momentum and nesterov")
+	flag.Var((*float32Value)(&c.Rate), "rate", "the learning rate of rmsprop and the adam optimizers")
+	flag.Var((*float32Value)(&c.Beta1), "beta1", "the decay rate of the first adam moment")
+	flag.Var((*float32Value)(&c.Beta2), "beta2", "the decay rate of the second adam moment")
+	flag.Var((*float32Value)(&c.Epsilon), "epsilon", "prevents division by zero")
+	flag.Var((*float32Value)(&c.Rho), "rho", "the decay rate of rmsprop and adadelta")
+	flag.Var((*float32Value)(&c.Decay), "decay", "the weight decay of adamw")
+	flag.Var(&c.Schedule, "schedule", "the learning rate schedule: constant, warmup, step, exponential, cosine, onecycle, plateau")
+	flag.IntVar(&c.Warmup, "warmup", 0, "the number of epochs of linear learning rate warmup")
+	flag.Var((*float32Value)(&c.Gamma), "gamma", "the learning rate decay of the step, exponential and plateau schedules")
+	flag.IntVar(&c.StepSize, "step-size", 0, "the number of epochs between decays of the step and exponential schedules")
+	flag.IntVar(&c.Period, "period", 0, "the number of epochs of the first cosine cycle")
+	flag.IntVar(&c.PeriodMult, "period-mult", 0, "the growth of the cosine cycle after each restart")
+	flag.Var((*float32Value)(&c.MinRate), "min-rate", "the smallest learning rate multiplier of the cosine and one cycle schedules")
+	flag.IntVar(&c.Patience, "patience", 0, "the number of epochs without improvement before the plateau schedule decays")
+	flag.IntVar(&c.Genetic.Population, "population", 0, "the number of networks of the genetic algorithm")
+	flag.IntVar(&c.Genetic.Elitism, "elitism", 0, "the number of the best networks that survive each generation")
+	flag.Var(&c.Genetic.Selection, "selection", "the selection of the parents: truncation, tournament, roulette, rank")
+	flag.IntVar(&c.Genetic.Parents, "parents", 0, "the number of the best networks truncation selection picks from, the elitism if 0")
+	flag.IntVar(&c.Genetic.Tournament, "tournament", 0, "the number of networks in a tournament")
+	flag.Var(&c.Genetic.Crossover, "crossover", "the crossover of the genomes: pair, uniform, blend")
+	flag.Var((*float32Value)(&c.Genetic.CrossoverRate), "crossover-rate", "the probability of a crossover")
+	flag.IntVar(&c.Genetic.Generations, "generations", 0, "the maximum number of generations")
+	flag.Var(&c.Genetic.Mutation, "mutation", "the mutation of the networks: gradient, gaussian, pair, reinit, hybrid")
+	flag.Var((*float32Value)(&c.Genetic.Sigma), "sigma", "the initial standard deviation of the noise of the mutations")
+	flag.IntVar(&c.Genetic.Islands, "islands", 0, "the number of populations of the genetic algorithm that evolve on their own")
+	flag.Var(&c.Genetic.Topology, "topology", "the connections between the islands: ring, full")
+	flag.IntVar(&c.Genetic.Interval, "migration-interval", 0, "the number of generations between migrations")
+	flag.IntVar(&c.Genetic.Migrants, "migrants", 0, "the number of the best networks of an island that migrate to each neighbor")
+	flag.BoolVar(&c.Clip, "clip", false, "clip the norm of the gradient to 1")
+	flag.Var(&c.Loss, "loss", "the cost function: default, quadratic, mse, mae, huber, bce, cce, hinge, focal")
+	flag.Var(&c.Criterion, "criterion", "the convergence criterion: total compares the epoch cost to the thresholds, mean compares the mean batch cost to the threshold of the loss")
+	flag.Var((*float32Value)(&c.LossThreshold), "loss-threshold", "replaces the threshold of the loss for the mean criterion")
+	flag.Var((*float32Value)(&c.Threshold), "threshold", "the epoch cost below which training has converged")
+	flag.Var((*float32Value)(&c.BatchThreshold), "batch-threshold", "the convergence threshold when batching, the threshold divided by the batch size if 0")
+	flag.Var((*float32Value)(&c.AdamThreshold), "adam-threshold", "the convergence threshold for rmsprop, adam, adamw and amsgrad without batching")
+	flag.Float64Var(&c.Validation, "validation", 0, "the fraction of each class held out for validation")
+	flag.Float64Var(&c.Test, "test", 0, "the fraction of each class held out for testing")
+}
@@ -0,0 +1,347 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/rpc"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultLease is how long a worker can go without being heard from before its jobs are handed out again
+	DefaultLease = time.Minute
+	// DefaultHeartbeat is the time between the heartbeats of a worker
+	DefaultHeartbeat = 10 * time.Second
+)
+
+// Job is a run of an experiment that is handed out to a worker
+type Job struct {
+	// ID identifies the job, it is 0 if there are no more jobs
+	ID        int
+	Dataset   string
+	Config    Config
+	Seed      int64
+	Optimizer OptimizerType
+	Mode      Mode
+	Batch     bool
+	Context   bool
+}
+
+// Outcome is the result of a job
+type Outcome struct {
+	ID     int
+	Result Result
+}
+
+// pending is a job that hasn't been done
+type pending struct {
+	Job
+	// handed is the number of times the job has been handed out
+	handed int
+	// worker is the worker the job was last handed to
+	worker string
+	result chan Result
+}
+
+// Coordinator hands out the runs of experiments to worker processes over net/rpc
+type Coordinator struct {
+	mutex sync.Mutex
+	// available is signaled when jobs are queued or the coordinator is closed
+	available *sync.Cond
+	queue     []*pending
+	jobs      map[int]*pending
+	// seen is when each worker was last heard from
+	seen     map[string]time.Time
+	next     int
+	closed   bool
+	listener net.Listener
+	// Lease is how long a worker can go without being heard from before its jobs are handed out again
+	Lease time.Duration
+}
+
+// NewCoordinator listens for workers on an address
+func NewCoordinator(address string) (*Coordinator, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c := &Coordinator{
+		jobs:     make(map[int]*pending),
+		seen:     make(map[string]time.Time),
+		listener: listener,
+		Lease:    DefaultLease,
+	}
+	c.available = sync.NewCond(&c.mutex)
+	server := rpc.NewServer()
+	err = server.RegisterName("Coordinator", &Work{c})
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	go func() {
+		// accepting stops when the listener is closed
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+	return c, nil
+}
+
+// Address is the address the coordinator listens on
+func (c *Coordinator) Address() string {
+	return c.listener.Addr().String()
+}
+
+// Run hands out a job and waits for its result
+// If the context is done the job is withdrawn and the result is canceled
+func (c *Coordinator) Run(ctx context.Context, job Job) Result {
+	c.mutex.Lock()
+	c.next++
+	job.ID = c.next
+	p := &pending{
+		Job:    job,
+		result: make(chan Result, 1),
+	}
+	c.jobs[job.ID] = p
+	c.queue = append(c.queue, p)
+	c.available.Broadcast()
+	c.mutex.Unlock()
+
+	select {
+	case result := <-p.result:
+		return result
+	case <-ctx.Done():
+		c.mutex.Lock()
+		delete(c.jobs, job.ID)
+		c.mutex.Unlock()
+		return Result{Canceled: true}
+	}
+}
+
+// Close tells the waiting workers that there are no more jobs and stops listening
+func (c *Coordinator) Close() error {
+	c.mutex.Lock()
+	c.closed = true
+	c.available.Broadcast()
+	c.mutex.Unlock()
+	return c.listener.Close()
+}
+
+// Work is the net/rpc service of a coordinator
+type Work struct {
+	c *Coordinator
+}
+
+// Next waits for a job, the job has an ID of 0 if there are no more jobs
+// Once every job has been handed out the jobs of the workers that haven't been heard from for the lease
+// are handed out again, so a worker that quits doesn't hold up the experiment
+func (w *Work) Next(worker string, job *Job) error {
+	c := w.c
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for {
+		now := time.Now()
+		c.seen[worker] = now
+		if c.closed {
+			*job = Job{}
+			return nil
+		}
+		for len(c.queue) > 0 {
+			p := c.queue[0]
+			c.queue = c.queue[1:]
+			// the withdrawn jobs are skipped
+			if c.jobs[p.ID] == p {
+				p.handed++
+				p.worker = worker
+				*job = p.Job
+				return nil
+			}
+		}
+		var backup *pending
+		// wait is the time until the next lease expires
+		wait := time.Duration(0)
+		for _, p := range c.jobs {
+			if left := c.seen[p.worker].Add(c.Lease).Sub(now); left > 0 {
+				if wait == 0 || left < wait {
+					wait = left
+				}
+				continue
+			}
+			if backup == nil || p.handed < backup.handed || (p.handed == backup.handed && p.ID < backup.ID) {
+				backup = p
+			}
+		}
+		if backup != nil {
+			backup.handed++
+			backup.worker = worker
+			*job = backup.Job
+			return nil
+		}
+		if wait > 0 {
+			timer := time.AfterFunc(wait, func() {
+				c.mutex.Lock()
+				c.available.Broadcast()
+				c.mutex.Unlock()
+			})
+			c.available.Wait()
+			timer.Stop()
+			continue
+		}
+		c.available.Wait()
+	}
+}
+
+// Heartbeat tells the coordinator that a worker is still running its jobs
+func (w *Work) Heartbeat(worker string, ok *bool) error {
+	c := w.c
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.seen[worker] = time.Now()
+	*ok = true
+	return nil
+}
+
+// Done returns the result of a job, the results of jobs that are already done are ignored
+func (w *Work) Done(outcome Outcome, ok *bool) error {
+	c := w.c
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	p := c.jobs[outcome.ID]
+	if p == nil {
+		*ok = false
+		return nil
+	}
+	delete(c.jobs, outcome.ID)
+	// gob decodes empty slices as nil
+	result := outcome.Result
+	if result.Costs == nil {
+		result.Costs = []float32{}
+	}
+	if result.ValidationCosts == nil {
+		result.ValidationCosts = []float32{}
+	}
+	p.result <- result
+	*ok = true
+	return nil
+}
+
+// Worker runs the jobs of a coordinator
+type Worker struct {
+	// Name identifies the worker to the coordinator
+	Name string
+	// Checkpoints is the directory of the checkpoints, there is no checkpointing if it is empty
+	Checkpoints string
+	// Interval is the number of epochs between checkpoints
+	Interval int
+	// Workers is the number of concurrent runs, GOMAXPROCS if it isn't positive
+	Workers int
+	// Heartbeat is the time between heartbeats, DefaultHeartbeat if it isn't positive
+	// It should be well under the lease of the coordinator
+	Heartbeat time.Duration
+}
+
+// Work runs jobs until the coordinator has no more jobs or has exited
+// If the context is done the running jobs are abandoned and the error of the context is returned
+func (w Worker) Work(ctx context.Context, address string) error {
+	client, err := rpc.Dial("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	workers := w.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	heartbeat := w.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				var ok bool
+				if client.Call("Coordinator.Heartbeat", w.Name, &ok) != nil {
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	defer close(stop)
+
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			errs <- w.work(ctx, client)
+		}()
+	}
+	for i := 0; i < workers; i++ {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// work runs one job at a time
+func (w Worker) work(ctx context.Context, client *rpc.Client) error {
+	// the connection is lost when the coordinator exits once it has the results of every job
+	gone := func(err error) bool {
+		_, remote := err.(rpc.ServerError)
+		return err != nil && !remote
+	}
+	for {
+		var job Job
+		call := client.Go("Coordinator.Next", w.Name, &job, nil)
+		select {
+		case <-call.Done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if gone(call.Error) {
+			return nil
+		} else if call.Error != nil {
+			return call.Error
+		} else if job.ID == 0 {
+			return nil
+		}
+
+		dataset, ok := Datasets[job.Dataset]
+		if !ok {
+			return fmt.Errorf("unknown dataset %s, a csv dataset needs the csv flags of the coordinator", job.Dataset)
+		}
+		experiment := Experiment{
+			Dataset:     dataset,
+			Config:      job.Config,
+			Checkpoints: w.Checkpoints,
+			Interval:    w.Interval,
+		}
+		result := experiment.Run(ctx, job.Seed, job.Optimizer, job.Mode, job.Batch, job.Context)
+		if result.Canceled {
+			return ctx.Err()
+		}
+		err := client.Call("Coordinator.Done", Outcome{ID: job.ID, Result: result}, &ok)
+		if gone(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
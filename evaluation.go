@@ -0,0 +1,130 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Split does a stratified split of the data into training, validation, and test sets
+// validation and test are the fractions of each class that go into those sets
+func Split(rnd *rand.Rand, data []Datum, validation, test float64) (train, valid, tests []Datum, err error) {
+	return split(rnd, data, validation, test, func(datum Datum) string {
+		return fmt.Sprint(datum.Output)
+	})
+}
+
+// SplitShuffled does a plain shuffled split of the data into training, validation, and test sets
+// It is for regressions, where each output would be a class of its own in a stratified split
+func SplitShuffled(rnd *rand.Rand, data []Datum, validation, test float64) (train, valid, tests []Datum, err error) {
+	return split(rnd, data, validation, test, func(datum Datum) string {
+		return ""
+	})
+}
+
+// split splits each group of the data with the same key into training, validation, and test sets
+func split(rnd *rand.Rand, data []Datum, validation, test float64, key func(datum Datum) string) (train, valid, tests []Datum, err error) {
+	err = CheckFractions(validation, test)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	classes, keys := make(map[string][]Datum), []string{}
+	for _, datum := range data {
+		key := key(datum)
+		if _, ok := classes[key]; !ok {
+			keys = append(keys, key)
+		}
+		classes[key] = append(classes[key], datum)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		class := classes[key]
+		rnd.Shuffle(len(class), func(i, j int) {
+			class[i], class[j] = class[j], class[i]
+		})
+		length := float64(len(class))
+		t, v := int(math.Round(length*test)), int(math.Round(length*validation))
+		tests = append(tests, class[:t]...)
+		valid = append(valid, class[t:t+v]...)
+		train = append(train, class[t+v:]...)
+	}
+	if len(train) == 0 {
+		return nil, nil, nil, fmt.Errorf("the validation fraction %g and test fraction %g leave no training data", validation, test)
+	}
+	return
+}
+
+// CheckFractions checks that the validation and test fractions aren't negative and leave some training data
+func CheckFractions(validation, test float64) error {
+	if validation < 0 || test < 0 {
+		return fmt.Errorf("the validation fraction %g and test fraction %g should not be negative", validation, test)
+	}
+	if validation+test >= 1 {
+		return fmt.Errorf("the validation fraction %g and test fraction %g should add up to less than 1", validation, test)
+	}
+	return nil
+}
+
+// Confusion is a confusion matrix indexed by the expected class and then the actual class
+type Confusion [][]int
+
+// NewConfusion creates a new confusion matrix
+func NewConfusion(classes int) Confusion {
+	confusion := make(Confusion, classes)
+	for i := range confusion {
+		confusion[i] = make([]int, classes)
+	}
+	return confusion
+}
+
+// Add adds the counts of another confusion matrix with the same classes
+func (c Confusion) Add(other Confusion) {
+	for i := range c {
+		for j := range c[i] {
+			c[i][j] += other[i][j]
+		}
+	}
+}
+
+// Total is the number of classified datums
+func (c Confusion) Total() int {
+	total := 0
+	for _, row := range c {
+		for _, count := range row {
+			total += count
+		}
+	}
+	return total
+}
+
+// Accuracy is the fraction of datums classified correctly
+func (c Confusion) Accuracy() float64 {
+	correct := 0
+	for i := range c {
+		correct += c[i][i]
+	}
+	return float64(correct) / float64(c.Total())
+}
+
+// Precision is the fraction of datums classified as class that are class
+func (c Confusion) Precision(class int) float64 {
+	total := 0
+	for i := range c {
+		total += c[i][class]
+	}
+	return float64(c[class][class]) / float64(total)
+}
+
+// Recall is the fraction of datums of class that are classified as class
+func (c Confusion) Recall(class int) float64 {
+	total := 0
+	for _, count := range c[class] {
+		total += count
+	}
+	return float64(c[class][class]) / float64(total)
+}
@@ -0,0 +1,708 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+
+	"github.com/pointlander/gradient/tf32"
+)
+
+// Datum is a single input and expected output
+type Datum struct {
+	Input  []float32
+	Output []float32
+}
+
+// Dataset is a problem for a network to learn
+type Dataset interface {
+	// Name is the name of the dataset
+	Name() string
+	// Data returns a fresh copy of the data
+	Data() []Datum
+	// Activation is the activation function of the output layer
+	Activation(o *Operators, a tf32.Meta) tf32.Meta
+	// Output is the activation function of the output layer for inference
+	Output() Activation
+	// Task is classification if the outputs are one hot classes
+	Task() Task
+	// DefaultLoss is the cost function of the output if the configuration doesn't have one
+	DefaultLoss() Loss
+	// Classes is the number of classes of the outputs
+	Classes() int
+	// Label is the class of an output
+	Label(output []float32) int
+	// Labels are the names of the classes of a classification
+	Labels() []string
+}
+
+// Normalizer is a dataset with features that are normalized with the statistics of the training data
+type Normalizer interface {
+	// Normalize normalizes the features of the training data and the other data
+	Normalize(train []Datum, others ...[]Datum)
+}
+
+// Datasets are the datasets by name
+var Datasets = map[string]Dataset{
+	"xor":  XORDataset{},
+	"iris": IrisDataset{},
+}
+
+// Register makes a dataset available by name
+// A dataset can't replace a dataset with the same name, so the checkpoints of a name are always of the same data
+func Register(dataset Dataset) error {
+	if _, ok := Datasets[dataset.Name()]; ok {
+		return fmt.Errorf("there is already a dataset named %s", dataset.Name())
+	}
+	Datasets[dataset.Name()] = dataset
+	return nil
+}
+
+// Experiment is a dataset and the configuration used to learn it
+type Experiment struct {
+	Dataset
+	Config
+	// Checkpoints is the directory of the checkpoints, there is no checkpointing if it is empty
+	Checkpoints string
+	// Interval is the number of epochs between checkpoints
+	Interval int
+	// Output collects the results if it isn't nil
+	Output *Output
+	// Workers is the number of concurrent local runs of the repeated experiment, GOMAXPROCS if it isn't positive
+	Workers int
+	// Progress is where the progress of the repeated experiment is reported, there is no reporting if it is nil
+	Progress io.Writer
+	// Coordinator hands out the runs of the repeated experiment to worker processes if it isn't nil,
+	// every run is queued with it at once, so only the connected workers limit the runs in progress
+	Coordinator *Coordinator
+	// Plot plots the median costs and the epochs to convergence of the repeated experiment
+	Plot bool
+	// LogScale plots the costs on a log scale
+	LogScale bool
+}
+
+// Run trains a network on the dataset
+// If the context is done the run is abandoned at the end of an epoch and the result is canceled
+func (e Experiment) Run(ctx context.Context, seed int64, optimizerType OptimizerType, mode Mode, batch, context bool) Result {
+	costs, validationCosts, converged, canceled, misses := make([]float32, 0, 1000), []float32{}, false, false, 0
+
+	type Sample struct {
+		Datum
+		index     int
+		optimizer Optimizer
+	}
+	batchSize := 1
+	if batch {
+		batchSize = e.BatchSize
+	}
+	result := Result{
+		Dataset:         e.Name(),
+		Seed:            seed,
+		Mode:            mode,
+		Optimizer:       optimizerType,
+		Batch:           batchSize,
+		Context:         context,
+		Costs:           costs,
+		ValidationCosts: validationCosts,
+		Config:          e.Config,
+	}
+	data, validation, test := e.Data(), []Datum{}, []Datum{}
+	if e.Validation > 0 || e.Test > 0 {
+		var err error
+		data, validation, test, err = e.Split(rand.New(rand.NewSource(seed)), data)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+	if normalizer, ok := e.Dataset.(Normalizer); ok {
+		normalizer.Normalize(data, validation, test)
+	}
+	samples := make([]Sample, len(data))
+	table := make([]*Sample, len(samples))
+	for i := range samples {
+		samples[i].Datum, samples[i].index = data[i], i
+		table[i] = &samples[i]
+	}
+	in, out := len(data[0].Input), len(data[0].Output)
+
+	network := NewNetwork(rand.New(rand.NewSource(seed)), e.Dataset, e.Config, mode, in, out, batchSize)
+	parameters := network.Parameters
+
+	optimizer := optimizerType.New(e.Hyperparameters)
+	if context {
+		for i := range samples {
+			samples[i].optimizer = optimizerType.New(e.Hyperparameters)
+		}
+	}
+
+	source := NewCountingSource(seed)
+	rnd := rand.New(source)
+	clip := func() float32 {
+		if !e.Clip {
+			return 1
+		}
+		return Clip(parameters)
+	}
+
+	checkpoint := Checkpoint{
+		Version:   CheckpointVersion,
+		Dataset:   e.Name(),
+		Config:    e.Config,
+		Seed:      seed,
+		Optimizer: optimizerType,
+		Mode:      mode,
+		Batch:     batch,
+		Context:   context,
+	}
+	name := ""
+	if e.Checkpoints != "" {
+		name = filepath.Join(e.Checkpoints, checkpoint.Name())
+	}
+	save := func(epoch int) {
+		if name == "" {
+			return
+		}
+		checkpoint.Epoch, checkpoint.Draws, checkpoint.Converged = epoch, source.Draws, converged
+		checkpoint.Costs, checkpoint.ValidationCosts = costs, validationCosts
+		checkpoint.Parameters = make([][]float32, len(parameters))
+		for i, p := range parameters {
+			checkpoint.Parameters[i] = p.X
+		}
+		optimizers := []Optimizer{optimizer}
+		if context {
+			for i := range samples {
+				optimizers = append(optimizers, samples[i].optimizer)
+			}
+		}
+		err := checkpoint.Store(optimizers...)
+		if err != nil {
+			panic(err)
+		}
+		checkpoint.Order = make([]int, len(table))
+		for i, sample := range table {
+			checkpoint.Order[i] = sample.index
+		}
+		err = checkpoint.Save(name)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	start := 0
+	if name != "" {
+		saved, err := LoadCheckpoint(name)
+		if err == nil && saved.Matches(&checkpoint) {
+			for i, p := range parameters {
+				copy(p.X, saved.Parameters[i])
+			}
+			optimizers, err := saved.Restore()
+			if err != nil {
+				panic(err)
+			}
+			optimizer = optimizers[0]
+			if context {
+				for i := range samples {
+					samples[i].optimizer = optimizers[i+1]
+				}
+			}
+			for i, index := range saved.Order {
+				table[i] = &samples[index]
+			}
+			source.Skip(saved.Draws)
+			start, converged = saved.Epoch, saved.Converged
+			costs, validationCosts = append(costs, saved.Costs...), append(validationCosts, saved.ValidationCosts...)
+		} else if err != nil && !os.IsNotExist(err) {
+			panic(err)
+		}
+	}
+
+	schedule := e.Schedule.New(e.Hyperparameters, e.Epochs)
+	length := len(table)
+	for i := start; i < e.Epochs && !converged; i++ {
+		if ctx.Err() != nil {
+			canceled = true
+			break
+		}
+		rate := schedule.Rate(i, costs)
+		// a full batch is the same in any order
+		if batchSize < length {
+			for i := range table {
+				j := i + rnd.Intn(length-i)
+				table[i], table[j] = table[j], table[i]
+			}
+		}
+		total := float32(0.0)
+		for j := 0; j < length; j += batchSize {
+			for _, p := range parameters {
+				p.Zero()
+			}
+			for _, p := range network.Zero {
+				p.Zero()
+			}
+
+			inputs, outputs := make([]float32, 0, in*batchSize), make([]float32, 0, out*batchSize)
+			for k := 0; k < batchSize; k++ {
+				index := (j + k) % length
+				inputs = append(inputs, table[index].Input...)
+				outputs = append(outputs, table[index].Output...)
+			}
+			network.Input.Set(inputs)
+			network.Output.Set(outputs)
+			total += tf32.Gradient(network.Cost).X[0]
+			if context && !batch {
+				table[j].optimizer.Step(parameters, clip(), rate, i)
+			} else {
+				optimizer.Step(parameters, clip(), rate, i)
+			}
+		}
+		costs = append(costs, total)
+		if len(validation) > 0 {
+			cost, _ := network.Evaluate(validation)
+			validationCosts = append(validationCosts, cost)
+		}
+		if e.Converged(optimizerType, batch, total, (length+batchSize-1)/batchSize, network.Loss) {
+			converged = true
+		}
+		if e.Interval > 0 && (i+1)%e.Interval == 0 && !converged {
+			save(i + 1)
+		}
+	}
+	// an abandoned run can be resumed from its checkpoint
+	save(len(costs))
+	result.Costs, result.ValidationCosts = costs, validationCosts
+	if canceled {
+		result.Canceled = true
+		return result
+	}
+
+	if converged {
+		weights := Compress(network.Weights...)
+		for i := range samples {
+			output := network.Infer(samples[i].Input)
+			if compressed := network.Dense(weights, samples[i].Input); !Equal(output, compressed, 1e-5) {
+				// the run fails rather than keep weights that don't compute the function of the network
+				result.Error = fmt.Sprintf("compressed output %v should be %v", compressed, output)
+				converged, misses = false, 0
+				break
+			}
+			if e.Label(output) != e.Label(samples[i].Output) {
+				misses++
+			}
+		}
+	}
+
+	var confusion Confusion
+	if len(test) > 0 {
+		confusion = NewConfusion(e.Classes())
+		_, outputs := network.Evaluate(test)
+		for i, datum := range test {
+			confusion[e.Label(datum.Output)][e.Label(outputs[i*out:(i+1)*out])]++
+		}
+	}
+
+	result.Converged, result.Misses, result.Confusion = converged, misses, confusion
+	return result
+}
+
+// Split splits the data into training, validation and test data
+// The split of a classification is stratified and the split of a regression is shuffled
+func (e Experiment) Split(rnd *rand.Rand, data []Datum) (train, validation, test []Datum, err error) {
+	if e.Task() == TaskClassification {
+		return Split(rnd, data, e.Validation, e.Test)
+	}
+	return SplitShuffled(rnd, data, e.Validation, e.Test)
+}
+
+// RunRepeated runs the experiment for many seeds and prints a table of the statistics
+// The runs of every optimizer, batch and mode share a pool of workers or are handed out by the coordinator
+// If the context is done no more runs are started and the statistics are of the runs that finished
+// If a run fails the other runs are abandoned and the error of the failed run is returned without any statistics
+func (e Experiment) RunRepeated(parent context.Context, modes []Mode, contextual bool) error {
+	type Task struct {
+		optimizer OptimizerType
+		batch     bool
+		mode      Mode
+		context   bool
+	}
+	tasks := []Task{}
+	for _, optimizer := range Optimizers {
+		for _, batch := range []bool{false, true} {
+			for _, mode := range modes {
+				tasks = append(tasks, Task{optimizer, batch, mode, false})
+				// the per datum context only applies to stateful optimizers without batching
+				if contextual && !batch && optimizer != OptimizerStatic {
+					tasks = append(tasks, Task{optimizer, batch, mode, true})
+				}
+			}
+		}
+	}
+	statistics := make([]Statistics, len(tasks))
+	for i, task := range tasks {
+		statistics[i].Mode, statistics[i].Optimizer, statistics[i].Context = task.mode, task.optimizer, task.context
+		statistics[i].Config = e.Config
+		if task.batch {
+			statistics[i].Batch = e.BatchSize
+		} else {
+			statistics[i].Batch = 1
+		}
+	}
+
+	// the split only depends on the sizes of the classes or the data, so a split that fails would fail every run
+	if e.Validation > 0 || e.Test > 0 {
+		_, _, _, err := e.Split(rand.New(rand.NewSource(1)), e.Data())
+		if err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	var failed error
+
+	// the results are aggregated in the order of the tasks and seeds as soon as the results before them are done
+	var mutex sync.Mutex
+	results, done, next := make([]Result, len(tasks)*e.Seeds), make([]bool, len(tasks)*e.Seeds), 0
+	finished := 0
+	aggregate := func(index int) {
+		result := results[index]
+		statistics[index/e.Seeds].Aggregate(result)
+		if e.Plot {
+			statistics[index/e.Seeds].Costs = append(statistics[index/e.Seeds].Costs, result.Costs)
+		}
+		e.Output.AddResult(result)
+		finished++
+	}
+	progress := NewProgress(e.Progress, "epochs", e.Seeds, len(tasks))
+	run := func(seed int64, task Task) Result {
+		if e.Coordinator == nil {
+			return e.Run(ctx, seed, task.optimizer, task.mode, task.batch, task.context)
+		}
+		return e.Coordinator.Run(ctx, Job{
+			Dataset:   e.Name(),
+			Config:    e.Config,
+			Seed:      seed,
+			Optimizer: task.optimizer,
+			Mode:      task.mode,
+			Batch:     task.batch,
+			Context:   task.context,
+		})
+	}
+	workers := e.Workers
+	if e.Coordinator != nil {
+		// the runs of the coordinator only wait for their results, so there is one for each run
+		workers = len(results)
+	}
+	pool := NewPool(workers)
+	for i, task := range tasks {
+		for j := 0; j < e.Seeds && ctx.Err() == nil; j++ {
+			seed, task, index := int64(j+1), task, i*e.Seeds+j
+			name := fmt.Sprintf("%s %s %s batch %d context %t", e.Name(), task.mode, task.optimizer,
+				statistics[i].Batch, task.context)
+			pool.Submit(func() {
+				result := Result{Canceled: true}
+				if ctx.Err() == nil {
+					result = run(seed, task)
+				}
+				if !result.Canceled {
+					progress.Update(name, result.Converged, len(result.Costs))
+				}
+				mutex.Lock()
+				defer mutex.Unlock()
+				if result.Error != "" {
+					// the failed run is recorded but it isn't part of the statistics
+					if failed == nil {
+						failed = fmt.Errorf("%s seed %d: %s", name, seed, result.Error)
+					}
+					cancel()
+					e.Output.AddResult(result)
+					result = Result{Canceled: true}
+				}
+				results[index], done[index] = result, true
+				for next < len(results) && done[next] {
+					if !results[next].Canceled {
+						aggregate(next)
+					}
+					results[next] = Result{}
+					next++
+				}
+			})
+		}
+	}
+	pool.Close()
+	progress.Finish()
+	if failed != nil {
+		return failed
+	}
+	// the results after the first abandoned or unscheduled run are aggregated in order
+	for ; next < len(results); next++ {
+		if done[next] && !results[next].Canceled {
+			aggregate(next)
+		}
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "interrupted after %d of %d runs\n", finished, len(results))
+		partial := statistics[:0]
+		for _, statistic := range statistics {
+			if statistic.Count > 0 {
+				partial = append(partial, statistic)
+			}
+		}
+		statistics = partial
+	}
+	if e.Plot {
+		err := PlotCosts(fmt.Sprintf("cost_%s_median.png", e.Name()), statistics, e.LogScale)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	sort.Slice(statistics, func(i, j int) bool {
+		// the statistics without any convergence go last
+		if (statistics[i].Converged == 0) != (statistics[j].Converged == 0) {
+			return statistics[j].Converged == 0
+		}
+		return statistics[i].AverageEpochs() < statistics[j].AverageEpochs()
+	})
+	PrintTable(statistics)
+	PrintConfusions(statistics)
+	e.Output.AddStatistics(e.Name(), statistics...)
+	if e.Plot {
+		err := PlotEpochs(fmt.Sprintf("epochs_%s.png", e.Name()), fmt.Sprintf("%s epochs", e.Name()), statistics, e.LogScale)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// the other modes are compared to the first mode with the same optimizer, batch and context
+	comparisons := []Comparison{}
+	for i := range statistics {
+		baseline := &statistics[i]
+		if baseline.Mode != modes[0] {
+			continue
+		}
+		for j := range statistics {
+			other := &statistics[j]
+			if other.Mode != modes[0] && other.Optimizer == baseline.Optimizer &&
+				other.Batch == baseline.Batch && other.Context == baseline.Context {
+				comparisons = append(comparisons, Compare(baseline, other))
+			}
+		}
+	}
+	if len(comparisons) > 0 {
+		fmt.Println()
+		PrintComparisons(comparisons)
+		e.Output.AddComparisons(e.Name(), comparisons...)
+	}
+	return nil
+}
+
+// RunOnce runs the experiment once for each optimizer and mode and plots the costs
+// If the context is done the costs of the runs that finished are plotted
+// If a run fails the error of the run is returned without a plot
+func (e Experiment) RunOnce(ctx context.Context, seed int64, modes []Mode, context bool) error {
+	p, err := plot.New()
+	if err != nil {
+		panic(err)
+	}
+
+	p.Title.Text = fmt.Sprintf("%s epochs", e.Name())
+	p.X.Label.Text = "epoch"
+	costAxis(&p.Y, e.LogScale)
+	p.Legend.Top = true
+
+	index := 0
+	for _, optimizer := range Optimizers {
+		if ctx.Err() != nil {
+			break
+		}
+		for _, mode := range modes {
+			result := e.Run(ctx, seed, optimizer, mode, true, context)
+			if result.Canceled {
+				break
+			}
+			e.Output.AddResult(result)
+			if result.Error != "" {
+				return fmt.Errorf("%s %s %s seed %d: %s", e.Name(), mode, optimizer, seed, result.Error)
+			}
+
+			points := make(plotter.XYs, 0, len(result.Costs))
+			for i, cost := range result.Costs {
+				if plottable(float64(cost), e.LogScale) {
+					points = append(points, plotter.XY{X: float64(i), Y: float64(cost)})
+				}
+			}
+
+			scatter, err := plotter.NewScatter(points)
+			if err != nil {
+				panic(err)
+			}
+			scatter.GlyphStyle.Radius = vg.Length(1)
+			scatter.GlyphStyle.Shape = draw.CircleGlyph{}
+			scatter.GlyphStyle.Color = colors[index%len(colors)]
+			scatter.GlyphStyle.Radius = 2
+			index++
+
+			p.Add(scatter)
+			p.Legend.Add(fmt.Sprintf("%s %s", mode.String(), optimizer.String()), scatter)
+		}
+	}
+
+	err = p.Save(8*vg.Inch, 8*vg.Inch, fmt.Sprintf("cost_%s.png", e.Name()))
+	if err != nil {
+		panic(err)
+	}
+	return nil
+}
+
+// PrintTable prints the statistics as a markdown table
+func PrintTable(statistics []Statistics) {
+	headers, tested := []string{"Mode", "Optimizer", "Batch", "Context", "Converged", "Epochs",
+		"Median", "StdDev", "P25", "P75", "95% CI"}, false
+	for _, statistic := range statistics {
+		tested = tested || statistic.Tested > 0
+	}
+	if tested {
+		headers = append(headers, "Accuracy", "Precision", "Recall")
+	}
+	rows := make([][]string, len(statistics))
+	for i, statistic := range statistics {
+		lower, upper := statistic.ConfidenceInterval()
+		rows[i] = []string{
+			statistic.Mode.String(),
+			statistic.Optimizer.String(),
+			fmt.Sprintf("%d", statistic.Batch),
+			fmt.Sprintf("%t", statistic.Context),
+			fmt.Sprintf("%f", statistic.ConvergenceProbability()),
+			fmt.Sprintf("%f", statistic.AverageEpochs()),
+			fmt.Sprintf("%.1f", statistic.MedianEpochs()),
+			fmt.Sprintf("%.1f", statistic.StdDevEpochs()),
+			fmt.Sprintf("%.1f", statistic.PercentileEpochs(25)),
+			fmt.Sprintf("%.1f", statistic.PercentileEpochs(75)),
+			fmt.Sprintf("%.1f-%.1f", lower, upper),
+		}
+		if tested {
+			rows[i] = append(rows[i], fmt.Sprintf("%f", statistic.AverageAccuracy()),
+				formatClasses(statistic.Precision()), formatClasses(statistic.Recall()))
+		}
+	}
+	printMarkdown(headers, rows)
+}
+
+// formatClasses formats a value of each class for a table
+func formatClasses(values []float64) string {
+	fields := make([]string, len(values))
+	for i, value := range values {
+		fields[i] = fmt.Sprintf("%.2f", value)
+	}
+	return strings.Join(fields, "/")
+}
+
+// PrintConfusions prints the summed confusion matrix of the test sets of each of the statistics as a markdown table
+// The rows are the expected classes and the columns are the predicted classes
+func PrintConfusions(statistics []Statistics) {
+	for _, statistic := range statistics {
+		if statistic.Tested == 0 {
+			continue
+		}
+		fmt.Printf("\n%s\n\n", statistic.Name())
+		headers := []string{"Expected"}
+		for i := range statistic.Confusion {
+			headers = append(headers, fmt.Sprintf("%d", i))
+		}
+		rows := make([][]string, len(statistic.Confusion))
+		for i, counts := range statistic.Confusion {
+			rows[i] = []string{fmt.Sprintf("%d", i)}
+			for _, count := range counts {
+				rows[i] = append(rows[i], fmt.Sprintf("%d", count))
+			}
+		}
+		printMarkdown(headers, rows)
+	}
+}
+
+// PrintComparisons prints the comparisons as a markdown table
+func PrintComparisons(comparisons []Comparison) {
+	headers := []string{"Optimizer", "Batch", "Context", "Baseline", "Mode", "Speedup", "W", "N", "P"}
+	rows := make([][]string, len(comparisons))
+	for i, comparison := range comparisons {
+		rows[i] = []string{
+			comparison.Other.Optimizer.String(),
+			fmt.Sprintf("%d", comparison.Other.Batch),
+			fmt.Sprintf("%t", comparison.Other.Context),
+			comparison.Baseline.Mode.String(),
+			comparison.Other.Mode.String(),
+			fmt.Sprintf("%.2f", comparison.Speedup),
+			fmt.Sprintf("%.1f", comparison.W),
+			fmt.Sprintf("%d", comparison.N),
+			fmt.Sprintf("%.3g", comparison.P),
+		}
+	}
+	printMarkdown(headers, rows)
+}
+
+// printMarkdown prints a markdown table with padded columns
+func printMarkdown(headers []string, rows [][]string) {
+	sizes := make([]int, len(headers))
+	for i, header := range headers {
+		sizes[i] = len(header)
+	}
+	for _, row := range rows {
+		for j, entry := range row {
+			if length := len(entry); length > sizes[j] {
+				sizes[j] = length
+			}
+		}
+	}
+
+	fmt.Printf("| ")
+	for i, header := range headers {
+		fmt.Printf("%s", header)
+		spaces := sizes[i] - len(header)
+		for spaces > 0 {
+			fmt.Printf(" ")
+			spaces--
+		}
+		fmt.Printf(" | ")
+	}
+	fmt.Printf("\n| ")
+	for i, header := range headers {
+		dashes := len(header)
+		if sizes[i] > dashes {
+			dashes = sizes[i]
+		}
+		for dashes > 0 {
+			fmt.Printf("-")
+			dashes--
+		}
+		fmt.Printf(" | ")
+	}
+	fmt.Printf("\n")
+	for _, row := range rows {
+		fmt.Printf("| ")
+		for i, entry := range row {
+			spaces := sizes[i] - len(entry)
+			fmt.Printf("%s", entry)
+			for spaces > 0 {
+				fmt.Printf(" ")
+				spaces--
+			}
+			fmt.Printf(" | ")
+		}
+		fmt.Printf("\n")
+	}
+}
@@ -0,0 +1,491 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pointlander/gradient/tf32"
+)
+
+// Normalization is a method for scaling the columns of a dataset
+type Normalization int
+
+const (
+	// NormalizationNone leaves the values as they are
+	NormalizationNone Normalization = iota
+	// NormalizationMax divides all of the values by the largest value
+	NormalizationMax
+	// NormalizationMinMax scales each column to between 0 and 1
+	NormalizationMinMax
+	// NormalizationZScore scales each column to a mean of 0 and a standard deviation of 1
+	NormalizationZScore
+)
+
+// Normalizations the normalizations
+var Normalizations = [...]Normalization{
+	NormalizationNone,
+	NormalizationMax,
+	NormalizationMinMax,
+	NormalizationZScore,
+}
+
+// Converts the normalization to a string
+func (n Normalization) String() string {
+	switch n {
+	case NormalizationNone:
+		return "none"
+	case NormalizationMax:
+		return "max"
+	case NormalizationMinMax:
+		return "minmax"
+	case NormalizationZScore:
+		return "zscore"
+	}
+	return "unknown"
+}
+
+// Set sets the normalization from its name
+func (n *Normalization) Set(name string) error {
+	for _, normalization := range Normalizations {
+		if normalization.String() == name {
+			*n = normalization
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown normalization %s", name)
+}
+
+// Scaling is the offset and the scale of each column
+type Scaling struct {
+	Offset, Scale []float64
+}
+
+// Fit computes the scaling of the columns of the rows
+func (n Normalization) Fit(rows [][]float64) Scaling {
+	if len(rows) == 0 {
+		return Scaling{}
+	}
+	columns := len(rows[0])
+	scaling := Scaling{Offset: make([]float64, columns), Scale: make([]float64, columns)}
+	for i := range scaling.Scale {
+		scaling.Scale[i] = 1
+	}
+	switch n {
+	case NormalizationMax:
+		max := 0.0
+		for _, row := range rows {
+			for _, value := range row {
+				if value > max {
+					max = value
+				}
+			}
+		}
+		if max == 0 {
+			max = 1
+		}
+		for i := range scaling.Scale {
+			scaling.Scale[i] = max
+		}
+	case NormalizationMinMax:
+		for i := 0; i < columns; i++ {
+			min, max := math.Inf(1), math.Inf(-1)
+			for _, row := range rows {
+				min, max = math.Min(min, row[i]), math.Max(max, row[i])
+			}
+			scaling.Offset[i] = min
+			if scale := max - min; scale != 0 {
+				scaling.Scale[i] = scale
+			}
+		}
+	case NormalizationZScore:
+		length := float64(len(rows))
+		for i := 0; i < columns; i++ {
+			mean := 0.0
+			for _, row := range rows {
+				mean += row[i]
+			}
+			mean /= length
+			variance := 0.0
+			for _, row := range rows {
+				diff := row[i] - mean
+				variance += diff * diff
+			}
+			scaling.Offset[i] = mean
+			if deviation := math.Sqrt(variance / length); deviation != 0 {
+				scaling.Scale[i] = deviation
+			}
+		}
+	}
+	return scaling
+}
+
+// Apply scales the columns of a row in place
+func (s Scaling) Apply(row []float64) {
+	for i := range s.Scale {
+		row[i] = (row[i] - s.Offset[i]) / s.Scale[i]
+	}
+}
+
+// Normalize normalizes the columns of the rows in place
+func (n Normalization) Normalize(rows [][]float64) {
+	scaling := n.Fit(rows)
+	for _, row := range rows {
+		scaling.Apply(row)
+	}
+}
+
+// Target is the encoding of the labels of a dataset
+type Target int
+
+const (
+	// TargetOneHot is a one hot encoding of the label classes
+	TargetOneHot Target = iota
+	// TargetNumeric is the numeric value of the label columns
+	TargetNumeric
+)
+
+// Targets the targets
+var Targets = [...]Target{
+	TargetOneHot,
+	TargetNumeric,
+}
+
+// Converts the target to a string
+func (t Target) String() string {
+	switch t {
+	case TargetOneHot:
+		return "onehot"
+	case TargetNumeric:
+		return "numeric"
+	}
+	return "unknown"
+}
+
+// Set sets the target from its name
+func (t *Target) Set(name string) error {
+	for _, target := range Targets {
+		if target.String() == name {
+			*t = target
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown target %s", name)
+}
+
+// CSVOptions are the options for loading a csv file
+type CSVOptions struct {
+	// Comma is the field delimiter, a tab for tsv files
+	Comma rune
+	// Header is true if the first row names the columns
+	Header bool
+	// Features are the names or indexes of the input columns, all of the other columns if empty
+	Features []string
+	// Labels are the names or indexes of the label columns
+	Labels        []string
+	Target        Target
+	Normalization Normalization
+}
+
+// CSVDataset is a dataset loaded from a csv file
+type CSVDataset struct {
+	name          string
+	target        Target
+	normalization Normalization
+	data          []Datum
+	// classes are the distinct outputs in the order of the labels
+	classes [][]float32
+	// labels are the names of the one hot classes
+	labels []string
+}
+
+// LoadCSV loads a dataset from a csv file
+// The features aren't normalized until the training data is known, see Normalize
+func LoadCSV(name string, options CSVOptions) (*CSVDataset, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comma = options.Comma
+	if reader.Comma == 0 {
+		reader.Comma = ','
+		if strings.ToLower(filepath.Ext(name)) == ".tsv" {
+			reader.Comma = '\t'
+		}
+	}
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", name)
+	}
+	var header []string
+	if options.Header {
+		header, records = records[0], records[1:]
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s has no rows", name)
+	}
+
+	column := func(spec string) (int, error) {
+		spec = strings.TrimSpace(spec)
+		for i, name := range header {
+			if strings.TrimSpace(name) == spec {
+				return i, nil
+			}
+		}
+		index, err := strconv.Atoi(spec)
+		if err != nil || index < 0 || index >= len(records[0]) {
+			return 0, fmt.Errorf("unknown column %s", spec)
+		}
+		return index, nil
+	}
+	if len(options.Labels) == 0 {
+		return nil, fmt.Errorf("no label columns")
+	}
+	labels, isLabel := []int{}, make(map[int]bool)
+	for _, spec := range options.Labels {
+		index, err := column(spec)
+		if err != nil {
+			return nil, err
+		}
+		labels, isLabel[index] = append(labels, index), true
+	}
+	features := []int{}
+	for _, spec := range options.Features {
+		index, err := column(spec)
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, index)
+	}
+	if len(features) == 0 {
+		for i := range records[0] {
+			if !isLabel[i] {
+				features = append(features, i)
+			}
+		}
+	}
+
+	inputs := make([][]float64, len(records))
+	for i, record := range records {
+		inputs[i] = make([]float64, len(features))
+		for j, index := range features {
+			value, err := strconv.ParseFloat(strings.TrimSpace(record[index]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %v", i+1, err)
+			}
+			inputs[i][j] = value
+		}
+	}
+
+	dataset := &CSVDataset{
+		name:          strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)),
+		target:        options.Target,
+		normalization: options.Normalization,
+		data:          make([]Datum, len(records)),
+	}
+	outputs := make([][]float32, len(records))
+	switch options.Target {
+	case TargetOneHot:
+		keys, classes := []string{}, make(map[string]int)
+		for _, record := range records {
+			key := ""
+			for _, index := range labels {
+				key += strings.TrimSpace(record[index]) + ","
+			}
+			if _, ok := classes[key]; !ok {
+				keys = append(keys, key)
+			}
+			classes[key] = 0
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			classes[key] = i
+			dataset.labels = append(dataset.labels, strings.TrimSuffix(key, ","))
+		}
+		for i, record := range records {
+			key := ""
+			for _, index := range labels {
+				key += strings.TrimSpace(record[index]) + ","
+			}
+			outputs[i] = make([]float32, len(keys))
+			outputs[i][classes[key]] = 1
+		}
+		for i := range keys {
+			class := make([]float32, len(keys))
+			class[i] = 1
+			dataset.classes = append(dataset.classes, class)
+		}
+	case TargetNumeric:
+		for i, record := range records {
+			outputs[i] = make([]float32, len(labels))
+			for j, index := range labels {
+				value, err := strconv.ParseFloat(strings.TrimSpace(record[index]), 32)
+				if err != nil {
+					return nil, fmt.Errorf("row %d: %v", i+1, err)
+				}
+				outputs[i][j] = float32(value)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown target %d", options.Target)
+	}
+
+	for i := range records {
+		dataset.data[i].Input = make([]float32, len(features))
+		for j, value := range inputs[i] {
+			dataset.data[i].Input[j] = float32(value)
+		}
+		dataset.data[i].Output = outputs[i]
+	}
+	if options.Target == TargetNumeric {
+		seen := make(map[string]bool)
+		for _, output := range outputs {
+			if key := fmt.Sprint(output); !seen[key] {
+				seen[key] = true
+				dataset.classes = append(dataset.classes, output)
+			}
+		}
+		sort.Slice(dataset.classes, func(i, j int) bool {
+			a, b := dataset.classes[i], dataset.classes[j]
+			for k := range a {
+				if a[k] != b[k] {
+					return a[k] < b[k]
+				}
+			}
+			return false
+		})
+	}
+	return dataset, nil
+}
+
+// Name is the name of the csv file without the extension
+func (d *CSVDataset) Name() string {
+	return d.name
+}
+
+// Data returns the features before they are normalized and the targets
+func (d *CSVDataset) Data() []Datum {
+	data := make([]Datum, len(d.data))
+	copy(data, d.data)
+	return data
+}
+
+// Normalize normalizes the features of the data with the statistics of the training data
+// The validation and test data are scaled the same way as the training data, so their statistics don't leak into the training
+func (d *CSVDataset) Normalize(train []Datum, others ...[]Datum) {
+	rows := make([][]float64, len(train))
+	for i, datum := range train {
+		rows[i] = make([]float64, len(datum.Input))
+		for j, value := range datum.Input {
+			rows[i][j] = float64(value)
+		}
+	}
+	scaling := d.normalization.Fit(rows)
+	for _, data := range append([][]Datum{train}, others...) {
+		for i := range data {
+			row := make([]float64, len(data[i].Input))
+			for j, value := range data[i].Input {
+				row[j] = float64(value)
+			}
+			scaling.Apply(row)
+			// the inputs are replaced because they are shared with the dataset
+			input := make([]float32, len(row))
+			for j, value := range row {
+				input[j] = float32(value)
+			}
+			data[i].Input = input
+		}
+	}
+}
+
+// Activation is softmax for one hot targets and linear for numeric targets
+func (d *CSVDataset) Activation(o *Operators, a tf32.Meta) tf32.Meta {
+	if d.target == TargetOneHot {
+		return o.Normalize(a)
+	}
+	return a
+}
+
+// Output is softmax for one hot targets and identity for numeric targets
+func (d *CSVDataset) Output() Activation {
+	if d.target == TargetOneHot {
+		return ActivationSoftmax
+	}
+	return ActivationIdentity
+}
+
+// Task is classification for one hot targets and regression for numeric targets
+func (d *CSVDataset) Task() Task {
+	if d.target == TargetOneHot {
+		return TaskClassification
+	}
+	return TaskRegression
+}
+
+// DefaultLoss is the binary cross entropy cost for one hot targets and the quadratic cost for numeric targets
+func (d *CSVDataset) DefaultLoss() Loss {
+	if d.target == TargetOneHot {
+		return LossBinaryCrossEntropy
+	}
+	return LossQuadratic
+}
+
+// Classes is the number of distinct targets
+func (d *CSVDataset) Classes() int {
+	return len(d.classes)
+}
+
+// Label is the closest of the distinct targets
+func (d *CSVDataset) Label(output []float32) int {
+	min, label := float32(math.MaxFloat32), 0
+	for i, class := range d.classes {
+		distance := float32(0)
+		for j, value := range class {
+			diff := value - output[j]
+			distance += diff * diff
+		}
+		if distance < min {
+			min, label = distance, i
+		}
+	}
+	return label
+}
+
+// Labels are the label columns of the one hot classes joined by commas
+func (d *CSVDataset) Labels() []string {
+	return d.labels
+}
+
+// CSV is the experiment for a csv dataset
+var CSV = Experiment{
+	Config: Config{
+		Width:           8,
+		Depth:           4,
+		BatchSize:       10,
+		Epochs:          10000,
+		Seeds:           16,
+		Hyperparameters: DefaultHyperparameters(.1),
+		Clip:            true,
+		Threshold:       1,
+		BatchThreshold:  .1,
+		AdamThreshold:   1,
+		Validation:      .1,
+		Test:            .2,
+	},
+}
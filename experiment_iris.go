@@ -5,17 +5,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"math"
+	"io"
 	"math/rand"
-	"sort"
+	"os"
 	"sync"
 
-	"gonum.org/v1/plot"
-	"gonum.org/v1/plot/plotter"
-	"gonum.org/v1/plot/vg"
-	"gonum.org/v1/plot/vg/draw"
-
 	"github.com/pointlander/datum/iris"
 	"github.com/pointlander/gradient/tf32"
 )
@@ -31,32 +27,12 @@ func load() {
 	if err != nil {
 		panic(err)
 	}
-	max := 0.0
-	for _, item := range datum.Fisher {
-		for _, measure := range item.Measures {
-			if measure > max {
-				max = measure
-			}
-		}
-	}
-	for _, item := range datum.Fisher {
-		for i, measure := range item.Measures {
-			item.Measures[i] = measure / max
-		}
-	}
-
-	max = 0.0
-	for _, item := range datum.Bezdek {
-		for _, measure := range item.Measures {
-			if measure > max {
-				max = measure
-			}
-		}
-	}
-	for _, item := range datum.Bezdek {
-		for i, measure := range item.Measures {
-			item.Measures[i] = measure / max
+	for _, items := range [][]iris.Iris{datum.Fisher, datum.Bezdek} {
+		rows := make([][]float64, len(items))
+		for i, item := range items {
+			rows[i] = item.Measures
 		}
+		NormalizationMax.Normalize(rows)
 	}
 }
 
@@ -69,62 +45,22 @@ type IrisNetwork struct {
 	Parameters    []*tf32.V
 	Genome        [][]*tf32.V
 	Cost          tf32.Meta
-	Fitness       float32
+	// Inference is the cost without the partial derivatives
+	Inference tf32.Meta
+	Fitness   float32
+	// Optimizer is the gradient descent of the mutations
+	Optimizer Optimizer
+	// Clip clips the norm of the gradient to 1
+	Clip bool
+	// Config is the configuration of the network
+	Config Config
 }
 
-// NewIrisNetwork creates a new iris network
-func NewIrisNetwork(rnd *rand.Rand, seed int64, width, depth int) IrisNetwork {
+// NewIrisNetwork creates a new inception network for the iris dataset with the configuration
+func NewIrisNetwork(rnd *rand.Rand, seed int64, config Config) IrisNetwork {
 	once.Do(load)
 
-	random32 := func(a, b float32) float32 {
-		if rnd == nil {
-			return 0
-		}
-		return (b-a)*rnd.Float32() + a
-	}
-	batchSize := 10
-
-	input, output := tf32.NewV(4, batchSize), tf32.NewV(3, batchSize)
-	w1, b1, w2, b2 := tf32.NewV(4, width), tf32.NewV(width), tf32.NewV(width, 3), tf32.NewV(3)
-	parameters := []*tf32.V{&w1, &b1, &w2, &b2}
-	genome := make([][]*tf32.V, 4)
-
-	m1, m2, m1a, m2a := w1.Meta(), w2.Meta(), b1.Meta(), b2.Meta()
-	for i := 0; i < depth; i++ {
-		a, b := tf32.NewV(4, 4), tf32.NewV(4, width)
-		m1 = tf32.Add(tf32.Mul(a.Meta(), b.Meta()), m1)
-		parameters = append(parameters, &a, &b)
-		genome[0] = append(genome[0], &a, &b)
-	}
-	for i := 0; i < depth; i++ {
-		a, b := tf32.NewV(width, width), tf32.NewV(width)
-		m1a = tf32.Add(tf32.Mul(a.Meta(), b.Meta()), m1a)
-		parameters = append(parameters, &a, &b)
-		genome[1] = append(genome[1], &a, &b)
-	}
-	for i := 0; i < depth; i++ {
-		a, b := tf32.NewV(width, width), tf32.NewV(width, 3)
-		m2 = tf32.Add(tf32.Mul(a.Meta(), b.Meta()), m2)
-		parameters = append(parameters, &a, &b)
-		genome[2] = append(genome[2], &a, &b)
-	}
-	for i := 0; i < depth; i++ {
-		a, b := tf32.NewV(3, 3), tf32.NewV(3)
-		m2a = tf32.Add(tf32.Mul(a.Meta(), b.Meta()), m2a)
-		parameters = append(parameters, &a, &b)
-		genome[3] = append(genome[3], &a, &b)
-	}
-
-	for _, p := range parameters {
-		for i := 0; i < cap(p.X); i++ {
-			p.X = append(p.X, random32(-1, 1))
-		}
-	}
-
-	l1 := tf32.Sigmoid(tf32.Add(tf32.Mul(m1, input.Meta()), m1a))
-	l2 := tf32.Sigmoid(tf32.Add(tf32.Mul(m2, l1), m2a))
-	cost := tf32.Avg(tf32.CrossEntropy(l2, output.Meta()))
-
+	network := NewNetwork(rnd, IrisDataset{}, config, ModeInception, 4, 3, config.BatchSize)
 	data := make([]*iris.Iris, len(datum.Fisher))
 	for i := range data {
 		data[i] = &datum.Fisher[i]
@@ -133,16 +69,20 @@ func NewIrisNetwork(rnd *rand.Rand, seed int64, width, depth int) IrisNetwork {
 	return IrisNetwork{
 		Rnd:        rand.New(rand.NewSource(seed)),
 		Iris:       data,
-		BatchSize:  batchSize,
-		Input:      &input,
-		Output:     &output,
-		Parameters: parameters,
-		Genome:     genome,
-		Cost:       cost,
+		BatchSize:  config.BatchSize,
+		Input:      network.Input,
+		Output:     network.Output,
+		Parameters: network.Parameters,
+		Genome:     Genome(network),
+		Cost:       network.Cost,
+		Inference:  network.InferenceCost(),
+		Optimizer:  OptimizerStatic.New(config.Hyperparameters),
+		Clip:       config.Clip,
+		Config:     config,
 	}
 }
 
-// Fit get the fitness of the network
+// Fit get the fitness of the network without computing the partial derivatives
 func (i *IrisNetwork) Fit() float32 {
 	length := len(i.Iris)
 	total := float32(0.0)
@@ -159,7 +99,9 @@ func (i *IrisNetwork) Fit() float32 {
 		}
 		i.Input.Set(inputs)
 		i.Output.Set(outputs)
-		total += tf32.Gradient(i.Cost).X[0]
+		i.Inference(func(a *tf32.V) {
+			total += a.X[0]
+		})
 	}
 	i.Fitness = total
 	return total
@@ -192,537 +134,153 @@ func (i *IrisNetwork) Mutate() float32 {
 		i.Input.Set(inputs)
 		i.Output.Set(outputs)
 		total += tf32.Gradient(i.Cost).X[0]
-		eta, norm := float32(.1), float32(0)
-		for _, p := range i.Parameters {
-			for _, d := range p.D {
-				norm += d * d
-			}
-		}
-		norm = float32(math.Sqrt(float64(norm)))
-		if norm > 1 {
-			scaling := 1 / norm
-			for _, p := range i.Parameters {
-				for l, d := range p.D {
-					d *= scaling
-					p.X[l] -= eta * d
-				}
-			}
-		} else {
-			for _, p := range i.Parameters {
-				for l, d := range p.D {
-					p.X[l] -= eta * d
-				}
-			}
+		scaling := float32(1)
+		if i.Clip {
+			scaling = Clip(i.Parameters)
 		}
+		i.Optimizer.Step(i.Parameters, scaling, 1, 0)
 	}
 	i.Fitness = total
 	return total
 }
 
+// Genes are the parameters of the network and its factor pairs
+func (i *IrisNetwork) Genes() (parameters []*tf32.V, genome [][]*tf32.V) {
+	return i.Parameters, i.Genome
+}
+
+// Reinit draws a factor pair again from its initializers
+func (i *IrisNetwork) Reinit(rnd *rand.Rand, set, pair int) {
+	Reinit(rnd, i.Config, i.Genome[set][2*pair:2*pair+2])
+}
+
 // IrisParallelExperiment runs parallel version of experiment
-func IrisParallelExperiment(seed int64, depth int) (generatrions int) {
+// The networks have the width and depth of the configuration and evolve with its genetic algorithm
+// If the context is done the experiment is abandoned and the error of the context is returned
+func IrisParallelExperiment(ctx context.Context, seed int64, config Config) (generations int, err error) {
 	rnd := rand.New(rand.NewSource(seed))
-	networks := make([]IrisNetwork, 100)
+	networks := make([]IrisNetwork, config.Genetic.Size())
+	population := make([]Individual, len(networks))
 	for i := range networks {
-		networks[i] = NewIrisNetwork(rnd, seed+int64(i), 3, depth)
+		networks[i] = NewIrisNetwork(rnd, seed+int64(i), config)
+		population[i] = &networks[i]
 	}
-	done := make(chan float32, 8)
-	fit := func(n *IrisNetwork) {
-		done <- n.Fit()
+	loss := config.Loss
+	if loss == LossDefault {
+		loss = IrisDataset{}.DefaultLoss()
 	}
-	mutate := func(n *IrisNetwork) {
-		done <- n.Mutate()
+	batches := (len(networks[0].Iris) + config.BatchSize - 1) / config.BatchSize
+	converged := func(cost float32) bool {
+		return config.GeneticConverged(cost, batches, loss)
 	}
-
-	generatrions, rnd = 1000, rand.New(rand.NewSource(seed))
-	for i := 0; i < 1000; i++ {
-		for j := range networks {
-			go mutate(&networks[j])
-		}
-		for j := 0; j < 100; j++ {
-			<-done
-		}
-
-		tf32.Static.InferenceOnly = true
-		for j := range networks {
-			go fit(&networks[j])
-		}
-		for j := 0; j < 100; j++ {
-			<-done
-		}
-		tf32.Static.InferenceOnly = false
-		sort.Slice(networks, func(i, j int) bool {
-			return networks[i].Fitness < networks[j].Fitness
-		})
-		//fmt.Println(i, networks[0].Fitness)
-		if networks[0].Fitness < 13/float32(networks[0].BatchSize) {
-			generatrions = i
-			break
-		}
-
-		index := 50
-		for j := 0; j < 25; j++ {
-			a, b := rnd.Intn(50), rnd.Intn(50)
-			for a == b {
-				b = rnd.Intn(50)
-			}
-
-			childa := &networks[index]
-			for k, p := range childa.Parameters {
-				copy(p.X, networks[a].Parameters[k].X)
-			}
-			index++
-
-			childb := &networks[index]
-			for k, p := range childb.Parameters {
-				copy(p.X, networks[b].Parameters[k].X)
-			}
-			index++
-
-			set, x, y := rnd.Intn(4), rnd.Intn(depth), rnd.Intn(depth)
-			childa.Genome[set][x*2].X, childb.Genome[set][y*2].X =
-				childb.Genome[set][y*2].X, childa.Genome[set][x*2].X
-			childa.Genome[set][x*2+1].X, childb.Genome[set][y*2+1].X =
-				childb.Genome[set][y*2+1].X, childa.Genome[set][x*2+1].X
-		}
-	}
-	return
+	return config.Genetic.Evolve(ctx, rand.New(rand.NewSource(seed)), population, converged)
 }
 
 // RunIrisRepeatedParallelExperiment runs iris prarallel experiment repeatedly
-func RunIrisRepeatedParallelExperiment() {
-	total := 0
-	for i := 0; i < 256; i++ {
-		generations := IrisParallelExperiment(int64(i)+1, 4)
+// If the context is done no more seeds are run and the average is of the seeds that finished
+// The progress is reported to the writer if it isn't nil
+func RunIrisRepeatedParallelExperiment(ctx context.Context, config Config, output *Output, writer io.Writer) {
+	total, count := 0, 0
+	progress := NewProgress(writer, "generations", config.Seeds, 1)
+	for i := 0; i < config.Seeds; i++ {
+		generations, err := IrisParallelExperiment(ctx, int64(i)+1, config)
+		if err != nil {
+			progress.Finish()
+			fmt.Fprintf(os.Stderr, "interrupted after %d of %d seeds\n", count, config.Seeds)
+			break
+		}
 		total += generations
-		fmt.Println(i, generations, float64(total)/float64(i+1))
+		count++
+		output.AddGenerations("iris", int64(i)+1, generations)
+		progress.Update("iris", generations < config.Genetic.Generations, generations)
 	}
-	fmt.Printf("generations=%f\n", float64(total)/256)
-}
-
-// IrisExperiment iris neural network experiment
-func IrisExperiment(seed int64, width, depth int, optimizer Optimizer, batch, inception, dct, context bool) Result {
-	once.Do(load)
-
-	rnd, costs, converged, misses := rand.New(rand.NewSource(seed)), make([]float32, 0, 1000), false, 0
-	random32 := func(a, b float32) float32 {
-		return (b-a)*rnd.Float32() + a
+	if count > 0 {
+		fmt.Printf("generations=%f\n", float64(total)/float64(count))
 	}
+}
 
-	batchSize := 10
-
-	var input, output tf32.V
-	if batch {
-		input, output = tf32.NewV(4, batchSize), tf32.NewV(3, batchSize)
-	} else {
-		input, output = tf32.NewV(4), tf32.NewV(3)
-	}
-	w1, b1, w2, b2 := tf32.NewV(4, width), tf32.NewV(width), tf32.NewV(width, 3), tf32.NewV(3)
-	parameters, zero := []*tf32.V{&w1, &b1, &w2, &b2}, []*tf32.V{}
-	m1, m2, m1a, m2a := w1.Meta(), w2.Meta(), b1.Meta(), b2.Meta()
-	if dct {
-		t1, tt1 := DCT2(4)
-		t2, tt2 := DCT2(width)
-		t3, tt3 := DCT2(width)
-		t4, tt4 := DCT2(3)
-		w1b, b1b, w2b, b2b := tf32.NewV(4, width), tf32.NewV(width), tf32.NewV(width, 3), tf32.NewV(3)
-		m1 = tf32.Add(tf32.Mul(tt1.Meta(), tf32.T(tf32.Mul(m1, t1.Meta()))), w1b.Meta())
-		m1a = tf32.Add(tf32.Mul(tt2.Meta(), tf32.T(tf32.Mul(m1a, t2.Meta()))), b1b.Meta())
-		m2 = tf32.Add(tf32.Mul(tt3.Meta(), tf32.T(tf32.Mul(m2, t3.Meta()))), w2b.Meta())
-		m2a = tf32.Add(tf32.Mul(tt4.Meta(), tf32.T(tf32.Mul(m2a, t4.Meta()))), b2b.Meta())
-		zero = append(zero, &t1, &tt1, &t2, &tt2, &t3, &tt3, &t4, &tt4)
-		parameters = append(parameters, &w1b, &b1b, &w2b, &b2b)
-	} else if inception {
-		for i := 0; i < depth; i++ {
-			a, b := tf32.NewV(4, 4), tf32.NewV(4, width)
-			m1 = tf32.Add(tf32.Mul(a.Meta(), b.Meta()), m1)
-			parameters = append(parameters, &a, &b)
-		}
-		for i := 0; i < depth; i++ {
-			a, b := tf32.NewV(width, width), tf32.NewV(width)
-			m1a = tf32.Add(tf32.Mul(a.Meta(), b.Meta()), m1a)
-			parameters = append(parameters, &a, &b)
-		}
-		for i := 0; i < depth; i++ {
-			a, b := tf32.NewV(width, width), tf32.NewV(width, 3)
-			m2 = tf32.Add(tf32.Mul(a.Meta(), b.Meta()), m2)
-			parameters = append(parameters, &a, &b)
-		}
-		for i := 0; i < depth; i++ {
-			a, b := tf32.NewV(3, 3), tf32.NewV(3)
-			m2a = tf32.Add(tf32.Mul(a.Meta(), b.Meta()), m2a)
-			parameters = append(parameters, &a, &b)
-		}
-	}
+// IrisDataset is Fisher's iris dataset
+type IrisDataset struct{}
 
-	var deltas, m, v [][]float32
-	for _, p := range parameters {
-		for i := 0; i < cap(p.X); i++ {
-			p.X = append(p.X, random32(-1, 1))
-		}
-		switch optimizer {
-		case OptimizerMomentum:
-			deltas = append(deltas, make([]float32, len(p.X)))
-		case OptimizerAdam:
-			m = append(m, make([]float32, len(p.X)))
-			v = append(v, make([]float32, len(p.X)))
-		}
-	}
+// Name is the name of the dataset
+func (IrisDataset) Name() string {
+	return "iris"
+}
 
-	l1 := tf32.Sigmoid(tf32.Add(tf32.Mul(m1, input.Meta()), m1a))
-	l2 := tf32.Softmax(tf32.Add(tf32.Mul(m2, l1), m2a))
-	cost := tf32.Avg(tf32.CrossEntropy(l2, output.Meta()))
+// Data returns the normalized measures and one hot encoded labels
+func (IrisDataset) Data() []Datum {
+	once.Do(load)
 
-	type Datum struct {
-		iris         *iris.Iris
-		deltas, m, v [][]float32
-	}
 	data := make([]Datum, len(datum.Fisher))
-	table := make([]*Datum, len(data))
-	for i := range data {
-		data[i].iris = &datum.Fisher[i]
-		if context {
-			for _, p := range parameters {
-				switch optimizer {
-				case OptimizerMomentum:
-					data[i].deltas = append(data[i].deltas, make([]float32, len(p.X)))
-				case OptimizerAdam:
-					data[i].m = append(data[i].m, make([]float32, len(p.X)))
-					data[i].v = append(data[i].v, make([]float32, len(p.X)))
-				}
-			}
-		}
-		table[i] = &data[i]
-	}
-
-	rnd = rand.New(rand.NewSource(seed))
-	// momentum parameters
-	alpha, eta := float32(.1), float32(.1)
-	// adam parameters
-	a, beta1, beta2, epsilon := float32(.001), float32(.9), float32(.999), float32(1E-8)
-	optimize := func(i int) {
-		norm := float32(0)
-		for _, p := range parameters {
-			for _, d := range p.D {
-				norm += d * d
-			}
-		}
-		norm = float32(math.Sqrt(float64(norm)))
-		if norm > 1 {
-			scaling := 1 / norm
-			for k, p := range parameters {
-				for l, d := range p.D {
-					d *= scaling
-					switch optimizer {
-					case OptimizerStatic:
-						p.X[l] -= eta * d
-					case OptimizerMomentum:
-						deltas[k][l] = alpha*deltas[k][l] - eta*d
-						p.X[l] += deltas[k][l]
-					case OptimizerAdam:
-						m[k][l] = beta1*m[k][l] + (1-beta1)*d
-						v[k][l] = beta2*v[k][l] + (1-beta2)*d*d
-						t := float32(i + 1)
-						mCorrected := m[k][l] / (1 - pow(beta1, t))
-						vCorrected := v[k][l] / (1 - pow(beta2, t))
-						p.X[l] -= a * mCorrected / (sqrt(vCorrected) + epsilon)
-					}
-				}
-			}
-		} else {
-			for k, p := range parameters {
-				for l, d := range p.D {
-					switch optimizer {
-					case OptimizerStatic:
-						p.X[l] -= eta * d
-					case OptimizerMomentum:
-						deltas[k][l] = alpha*deltas[k][l] - eta*d
-						p.X[l] += deltas[k][l]
-					case OptimizerAdam:
-						m[k][l] = beta1*m[k][l] + (1-beta1)*d
-						v[k][l] = beta2*v[k][l] + (1-beta2)*d*d
-						t := float32(i + 1)
-						mCorrected := m[k][l] / (1 - pow(beta1, t))
-						vCorrected := v[k][l] / (1 - pow(beta2, t))
-						p.X[l] -= a * mCorrected / (sqrt(vCorrected) + epsilon)
-					}
-				}
-			}
-		}
-	}
-
-	length := len(table)
-	if batch {
-		for i := 0; i < 10000; i++ {
-			for i := range table {
-				j := i + rnd.Intn(length-i)
-				table[i], table[j] = table[j], table[i]
-			}
-			total := float32(0.0)
-			for j := 0; j < length; j += batchSize {
-				for _, p := range parameters {
-					p.Zero()
-				}
-				for _, p := range zero {
-					p.Zero()
-				}
-
-				inputs, outputs := make([]float32, 0, 4*batchSize), make([]float32, 0, 3*batchSize)
-				for k := 0; k < batchSize; k++ {
-					index := (j + k) % length
-					for _, measure := range table[index].iris.Measures {
-						inputs = append(inputs, float32(measure))
-					}
-					out := make([]float32, 3)
-					out[iris.Labels[table[index].iris.Label]] = 1
-					outputs = append(outputs, out...)
-				}
-				input.Set(inputs)
-				output.Set(outputs)
-				total += tf32.Gradient(cost).X[0]
-				optimize(i)
-			}
-			costs = append(costs, total)
-			if total < 13/float32(batchSize) {
-				converged = true
-				break
-			}
-		}
-	} else {
-		for i := 0; i < 10000; i++ {
-			for i := range table {
-				j := i + rnd.Intn(length-i)
-				table[i], table[j] = table[j], table[i]
-			}
-			total := float32(0.0)
-			for j := range table {
-				for _, p := range parameters {
-					p.Zero()
-				}
-				for _, p := range zero {
-					p.Zero()
-				}
-				in := make([]float32, len(table[j].iris.Measures))
-				for k, measure := range table[j].iris.Measures {
-					in[k] = float32(measure)
-				}
-				input.Set(in)
-				out := make([]float32, 3)
-				out[iris.Labels[table[j].iris.Label]] = 1
-				output.Set(out)
-				total += tf32.Gradient(cost).X[0]
-				if context {
-					switch optimizer {
-					case OptimizerMomentum:
-						deltas = table[j].deltas
-					case OptimizerAdam:
-						m = table[j].m
-						v = table[j].v
-					}
-				}
-				optimize(i)
-			}
-			costs = append(costs, total)
-			if total < 13 {
-				converged = true
-				break
-			}
-		}
-	}
-
-	if converged {
-		for i := range data {
-			in := make([]float32, len(data[i].iris.Measures))
-			for i, measure := range data[i].iris.Measures {
-				in[i] = float32(measure)
-			}
-			input.Set(in)
-			var output tf32.V
-			l2(func(a *tf32.V) {
-				output = *a
-			})
-			max, actual := float32(0.0), 0
-			expected := iris.Labels[data[i].iris.Label]
-			for j, value := range output.X {
-				if value > max {
-					max, actual = value, j
-				}
-			}
-			if expected != actual {
-				misses++
-			}
+	for i, item := range datum.Fisher {
+		data[i].Input = make([]float32, len(item.Measures))
+		for j, measure := range item.Measures {
+			data[i].Input[j] = float32(measure)
 		}
+		data[i].Output = make([]float32, 3)
+		data[i].Output[iris.Labels[item.Label]] = 1
 	}
+	return data
+}
 
-	return Result{
-		Costs:     costs,
-		Converged: converged,
-		Misses:    misses,
-	}
+// Activation is the softmax function
+func (IrisDataset) Activation(o *Operators, a tf32.Meta) tf32.Meta {
+	return o.Normalize(a)
 }
 
-// RunIrisRepeatedExperiment runs multiple iris experiments
-func RunIrisRepeatedExperiment() {
-	run := func(optimizer Optimizer, batch bool) (normalStats, inceptionStats Statistics) {
-		normalStats.Mode, inceptionStats.Mode = "normal", "inception"
-		normalStats.Optimizer, inceptionStats.Optimizer = optimizer, optimizer
-		if batch {
-			normalStats.Batch, inceptionStats.Batch = 10, 10
-		} else {
-			normalStats.Batch, inceptionStats.Batch = 1, 1
-		}
-		experiment := func(seed int64, inception, context bool, results chan<- Result) {
-			results <- IrisExperiment(seed, 3, 4, optimizer, batch, inception, false, context)
-		}
-		normalResults, inceptionResults := make(chan Result, 8), make(chan Result, 8)
-		for i := 1; i <= 256; i++ {
-			go experiment(int64(i), false, false, normalResults)
-			go experiment(int64(i), true, false, inceptionResults)
-		}
-		for normalStats.Count < 256 || inceptionStats.Count < 256 {
-			select {
-			case result := <-normalResults:
-				normalStats.Aggregate(result)
-			case result := <-inceptionResults:
-				inceptionStats.Aggregate(result)
-			}
-		}
-		return
-	}
+// Output is the softmax function
+func (IrisDataset) Output() Activation {
+	return ActivationSoftmax
+}
 
-	statistics := []Statistics{}
-	for _, optimizer := range Optimizers {
-		normalStats, inceptionStats := run(optimizer, false)
-		statistics = append(statistics, normalStats, inceptionStats)
+// Task is a classification of the species
+func (IrisDataset) Task() Task {
+	return TaskClassification
+}
 
-		normalStats, inceptionStats = run(optimizer, true)
-		statistics = append(statistics, normalStats, inceptionStats)
-	}
-	sort.Slice(statistics, func(i, j int) bool {
-		return statistics[i].AverageEpochs() < statistics[j].AverageEpochs()
-	})
+// DefaultLoss is the binary cross entropy cost
+func (IrisDataset) DefaultLoss() Loss {
+	return LossBinaryCrossEntropy
+}
 
-	headers := []string{"Mode", "Optimizer", "Batch", "Converged", "Epochs"}
-	sizes, results := make([]int, 5), make([][5]string, len(statistics))
-	for i, header := range headers {
-		sizes[i] = len(header)
-	}
-	for i, statistic := range statistics {
-		results[i][0] = statistic.Mode
-		if length := len(results[i][0]); length > sizes[0] {
-			sizes[0] = length
-		}
-		results[i][1] = statistic.Optimizer.String()
-		if length := len(results[i][1]); length > sizes[1] {
-			sizes[1] = length
-		}
-		results[i][2] = fmt.Sprintf("%d", statistic.Batch)
-		if length := len(results[i][2]); length > sizes[2] {
-			sizes[2] = length
-		}
-		results[i][3] = fmt.Sprintf("%f", statistic.ConvergenceProbability())
-		if length := len(results[i][3]); length > sizes[3] {
-			sizes[3] = length
-		}
-		results[i][4] = fmt.Sprintf("%f", statistic.AverageEpochs())
-		if length := len(results[i][4]); length > sizes[4] {
-			sizes[4] = length
-		}
-	}
+// Classes is the three species of iris
+func (IrisDataset) Classes() int {
+	return 3
+}
 
-	fmt.Printf("| ")
-	for i, header := range headers {
-		fmt.Printf("%s", header)
-		spaces := sizes[i] - len(header)
-		for spaces > 0 {
-			fmt.Printf(" ")
-			spaces--
+// Label is the most probable species
+func (IrisDataset) Label(output []float32) int {
+	max, label := float32(0.0), 0
+	for i, value := range output {
+		if value > max {
+			max, label = value, i
 		}
-		fmt.Printf(" | ")
-	}
-	fmt.Printf("\n| ")
-	for i, header := range headers {
-		dashes := len(header)
-		if sizes[i] > dashes {
-			dashes = sizes[i]
-		}
-		for dashes > 0 {
-			fmt.Printf("-")
-			dashes--
-		}
-		fmt.Printf(" | ")
-	}
-	fmt.Printf("\n")
-	for _, row := range results {
-		fmt.Printf("| ")
-		for i, entry := range row {
-			spaces := sizes[i] - len(entry)
-			fmt.Printf("%s", entry)
-			for spaces > 0 {
-				fmt.Printf(" ")
-				spaces--
-			}
-			fmt.Printf(" | ")
-		}
-		fmt.Printf("\n")
 	}
+	return label
 }
 
-// RunIrisExperiment runs an iris experiment once
-func RunIrisExperiment(seed int64) {
-	p, err := plot.New()
-	if err != nil {
-		panic(err)
-	}
-
-	p.Title.Text = "iris epochs"
-	p.X.Label.Text = "epoch"
-	p.Y.Label.Text = "cost"
-	p.Legend.Top = true
-
-	index := 0
-	for _, optimizer := range Optimizers {
-		normal := IrisExperiment(seed, 3, 4, optimizer, true, false, false, false)
-		inception := IrisExperiment(seed, 3, 4, optimizer, true, true, false, false)
-
-		pointsNormal := make(plotter.XYs, 0, len(normal.Costs))
-		for i, cost := range normal.Costs {
-			pointsNormal = append(pointsNormal, plotter.XY{X: float64(i), Y: float64(cost)})
-		}
-
-		pointsInception := make(plotter.XYs, 0, len(inception.Costs))
-		for i, cost := range inception.Costs {
-			pointsInception = append(pointsInception, plotter.XY{X: float64(i), Y: float64(cost)})
-		}
-
-		normalScatter, err := plotter.NewScatter(pointsNormal)
-		if err != nil {
-			panic(err)
-		}
-		normalScatter.GlyphStyle.Radius = vg.Length(1)
-		normalScatter.GlyphStyle.Shape = draw.CircleGlyph{}
-		normalScatter.GlyphStyle.Color = colors[index]
-		normalScatter.GlyphStyle.Radius = 2
-		index++
-
-		inceptionScatter, err := plotter.NewScatter(pointsInception)
-		if err != nil {
-			panic(err)
-		}
-		inceptionScatter.GlyphStyle.Radius = vg.Length(1)
-		inceptionScatter.GlyphStyle.Shape = draw.CircleGlyph{}
-		inceptionScatter.GlyphStyle.Color = colors[index]
-		inceptionScatter.GlyphStyle.Radius = 2
-		index++
-
-		p.Add(normalScatter, inceptionScatter)
-
-		p.Legend.Add(fmt.Sprintf("normal %s", optimizer.String()), normalScatter)
-		p.Legend.Add(fmt.Sprintf("inception %s", optimizer.String()), inceptionScatter)
+// Labels are the names of the species
+func (IrisDataset) Labels() []string {
+	labels := make([]string, len(iris.Labels))
+	for name, label := range iris.Labels {
+		labels[label] = name
 	}
+	return labels
+}
 
-	err = p.Save(8*vg.Inch, 8*vg.Inch, "cost_iris.png")
-	if err != nil {
-		panic(err)
-	}
+// Iris is the iris experiment
+var Iris = Experiment{
+	Dataset: IrisDataset{},
+	Config: Config{
+		Width:           3,
+		Depth:           4,
+		BatchSize:       10,
+		Epochs:          10000,
+		Seeds:           256,
+		Hyperparameters: DefaultHyperparameters(.1),
+		Genetic:         DefaultGenetic(),
+		Clip:            true,
+		Threshold:       13,
+		AdamThreshold:   13,
+	},
 }
@@ -0,0 +1,686 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/pointlander/gradient/tf32"
+)
+
+// Selection is a method for picking the parents of the children
+type Selection int
+
+const (
+	// SelectionTruncation picks uniformly from the best individuals
+	SelectionTruncation Selection = iota
+	// SelectionTournament picks the best of a few random individuals
+	SelectionTournament
+	// SelectionRoulette picks with a probability proportional to the inverse of the cost
+	SelectionRoulette
+	// SelectionRank picks with a probability that decreases linearly with the rank
+	SelectionRank
+)
+
+// Selections the selections
+var Selections = [...]Selection{
+	SelectionTruncation,
+	SelectionTournament,
+	SelectionRoulette,
+	SelectionRank,
+}
+
+// Converts the selection to a string
+func (s Selection) String() string {
+	switch s {
+	case SelectionTruncation:
+		return "truncation"
+	case SelectionTournament:
+		return "tournament"
+	case SelectionRoulette:
+		return "roulette"
+	case SelectionRank:
+		return "rank"
+	}
+	return "unknown"
+}
+
+// Set sets the selection from its name
+func (s *Selection) Set(name string) error {
+	for _, selection := range Selections {
+		if selection.String() == name {
+			*s = selection
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown selection %s", name)
+}
+
+// MarshalText converts the selection to its name
+func (s Selection) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText sets the selection from its name
+func (s *Selection) UnmarshalText(text []byte) error {
+	return s.Set(string(text))
+}
+
+// Crossover is a method for combining the genomes of two parents
+type Crossover int
+
+const (
+	// CrossoverPair swaps one random factor pair of a random set between the children
+	CrossoverPair Crossover = iota
+	// CrossoverUniform swaps each factor pair of each set with a probability of one half
+	CrossoverUniform
+	// CrossoverBlend makes each parameter of the children a random weighted average of the parents
+	CrossoverBlend
+)
+
+// Crossovers the crossovers
+var Crossovers = [...]Crossover{
+	CrossoverPair,
+	CrossoverUniform,
+	CrossoverBlend,
+}
+
+// Converts the crossover to a string
+func (c Crossover) String() string {
+	switch c {
+	case CrossoverPair:
+		return "pair"
+	case CrossoverUniform:
+		return "uniform"
+	case CrossoverBlend:
+		return "blend"
+	}
+	return "unknown"
+}
+
+// Set sets the crossover from its name
+func (c *Crossover) Set(name string) error {
+	for _, crossover := range Crossovers {
+		if crossover.String() == name {
+			*c = crossover
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown crossover %s", name)
+}
+
+// MarshalText converts the crossover to its name
+func (c Crossover) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText sets the crossover from its name
+func (c *Crossover) UnmarshalText(text []byte) error {
+	return c.Set(string(text))
+}
+
+// Mutation is a method for changing the individuals each generation
+type Mutation int
+
+const (
+	// MutationGradient is an epoch of gradient descent
+	MutationGradient Mutation = iota
+	// MutationGaussian adds gaussian noise to every parameter
+	MutationGaussian
+	// MutationPair adds gaussian noise to one random factor pair
+	MutationPair
+	// MutationReinit draws one random factor pair again from the initial distribution
+	MutationReinit
+	// MutationHybrid is an epoch of gradient descent followed by gaussian noise
+	MutationHybrid
+)
+
+// Mutations the mutations
+var Mutations = [...]Mutation{
+	MutationGradient,
+	MutationGaussian,
+	MutationPair,
+	MutationReinit,
+	MutationHybrid,
+}
+
+// Converts the mutation to a string
+func (m Mutation) String() string {
+	switch m {
+	case MutationGradient:
+		return "gradient"
+	case MutationGaussian:
+		return "gaussian"
+	case MutationPair:
+		return "pair"
+	case MutationReinit:
+		return "reinit"
+	case MutationHybrid:
+		return "hybrid"
+	}
+	return "unknown"
+}
+
+// Set sets the mutation from its name
+func (m *Mutation) Set(name string) error {
+	for _, mutation := range Mutations {
+		if mutation.String() == name {
+			*m = mutation
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown mutation %s", name)
+}
+
+// MarshalText converts the mutation to its name
+func (m Mutation) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText sets the mutation from its name
+func (m *Mutation) UnmarshalText(text []byte) error {
+	return m.Set(string(text))
+}
+
+// Adaptive is true if the mutation adds noise with a standard deviation that adapts to the success of the mutations
+func (m Mutation) Adaptive() bool {
+	return m == MutationGaussian || m == MutationPair || m == MutationHybrid
+}
+
+// UsesGenome is true if the crossover or the mutation picks factor pairs from the genome
+func (g Genetic) UsesGenome() bool {
+	return g.Crossover == CrossoverPair || g.Mutation == MutationPair || g.Mutation == MutationReinit
+}
+
+// Topology is the connections between the islands that migrants travel along
+type Topology int
+
+const (
+	// TopologyRing sends the migrants of each island to the next island
+	TopologyRing Topology = iota
+	// TopologyFull sends the migrants of each island to every other island
+	TopologyFull
+)
+
+// Topologies the topologies
+var Topologies = [...]Topology{
+	TopologyRing,
+	TopologyFull,
+}
+
+// Converts the topology to a string
+func (t Topology) String() string {
+	switch t {
+	case TopologyRing:
+		return "ring"
+	case TopologyFull:
+		return "full"
+	}
+	return "unknown"
+}
+
+// Set sets the topology from its name
+func (t *Topology) Set(name string) error {
+	for _, topology := range Topologies {
+		if topology.String() == name {
+			*t = topology
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown topology %s", name)
+}
+
+// MarshalText converts the topology to its name
+func (t Topology) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText sets the topology from its name
+func (t *Topology) UnmarshalText(text []byte) error {
+	return t.Set(string(text))
+}
+
+// Genetic is the configuration of the genetic algorithm of the parallel experiments
+type Genetic struct {
+	// Population is the number of individuals of each island
+	Population int `json:"population"`
+	// Elitism is the number of the best individuals of each island that survive each generation
+	Elitism int `json:"elitism"`
+	// Selection is the method for picking the parents
+	Selection Selection `json:"selection"`
+	// Parents is the number of the best individuals truncation selection picks from, the elitism if it is 0
+	Parents int `json:"parents"`
+	// Tournament is the number of individuals in a tournament
+	Tournament int `json:"tournament"`
+	// Crossover is the method for combining the genomes of the parents
+	Crossover Crossover `json:"crossover"`
+	// CrossoverRate is the probability of a crossover, otherwise the children are copies of the parents
+	CrossoverRate float32 `json:"crossover_rate"`
+	// Generations is the maximum number of generations
+	Generations int `json:"generations"`
+	// Mutation is the method for changing the individuals each generation
+	Mutation Mutation `json:"mutation"`
+	// Sigma is the initial standard deviation of the noise of the mutations
+	Sigma float32 `json:"sigma"`
+	// Islands is the number of populations that evolve on their own
+	Islands int `json:"islands"`
+	// Topology is the connections between the islands
+	Topology Topology `json:"topology"`
+	// Interval is the number of generations between migrations, there are no migrations if it is 0
+	Interval int `json:"interval"`
+	// Migrants is the number of the best individuals of an island that migrate to each neighbor
+	Migrants int `json:"migrants"`
+}
+
+// DefaultGenetic returns the default configuration of the genetic algorithm
+func DefaultGenetic() Genetic {
+	return Genetic{
+		Population:    100,
+		Elitism:       50,
+		Selection:     SelectionTruncation,
+		Tournament:    3,
+		Crossover:     CrossoverPair,
+		CrossoverRate: 1,
+		Generations:   1000,
+		Mutation:      MutationGradient,
+		Sigma:         .1,
+		Islands:       1,
+		Topology:      TopologyRing,
+		Interval:      10,
+		Migrants:      2,
+	}
+}
+
+// Individual is a member of the population of the genetic algorithm
+type Individual interface {
+	// Fit computes the cost of the individual without changing it
+	Fit() float32
+	// Mutate changes the individual with gradient descent
+	Mutate() float32
+	// Genes are the parameters of the individual and its factor pairs grouped into sets
+	Genes() (parameters []*tf32.V, genome [][]*tf32.V)
+	// Reinit draws a factor pair of a set again from its initializers
+	Reinit(rnd *rand.Rand, set, pair int)
+}
+
+// Genome groups the factor pairs of an inception network into a set for each factorized weight and bias
+func Genome(network *Network) (genome [][]*tf32.V) {
+	for _, factors := range network.Factors {
+		if len(factors) > 0 {
+			genome = append(genome, factors)
+		}
+	}
+	return genome
+}
+
+// ranked is an individual and its cost
+type ranked struct {
+	Individual
+	Cost float32
+	// Previous is the cost before the mutation
+	Previous float32
+}
+
+// Island is a population that evolves on its own between migrations
+type Island struct {
+	Genetic
+	Rnd         *rand.Rand
+	Individuals []ranked
+	// Sigma is the adapted standard deviation of the noise of the mutations
+	Sigma float32
+	// saved are the parameters of the parents that are replaced by children
+	saved [][][]float32
+}
+
+// NewIsland creates an island for a population
+func (g Genetic) NewIsland(rnd *rand.Rand, population []Individual) *Island {
+	if len(population) < 2 || g.Elitism < 0 || g.Elitism >= len(population) {
+		panic(fmt.Sprintf("elitism %d should be less than the population %d", g.Elitism, len(population)))
+	}
+	individuals := make([]ranked, len(population))
+	for i := range individuals {
+		individuals[i].Individual = population[i]
+	}
+	return &Island{
+		Genetic:     g,
+		Rnd:         rnd,
+		Individuals: individuals,
+		Sigma:       g.Sigma,
+		saved:       make([][][]float32, len(individuals)),
+	}
+}
+
+// parallel applies a function to each individual at the same time
+func (i *Island) parallel(f func(individual *ranked)) {
+	var group sync.WaitGroup
+	for j := range i.Individuals {
+		group.Add(1)
+		go func(individual *ranked) {
+			defer group.Done()
+			f(individual)
+		}(&i.Individuals[j])
+	}
+	group.Wait()
+}
+
+// Evaluate mutates the individuals, computes their costs and sorts them by cost
+func (i *Island) Evaluate() {
+	individuals := i.Individuals
+	if i.Mutation.Adaptive() {
+		i.parallel(func(individual *ranked) {
+			individual.Previous = individual.Fit()
+		})
+	}
+	if i.Mutation == MutationGradient || i.Mutation == MutationHybrid {
+		i.parallel(func(individual *ranked) {
+			individual.Mutate()
+		})
+	}
+	for j := range individuals {
+		i.Perturb(i.Rnd, individuals[j], i.Sigma)
+	}
+	i.parallel(func(individual *ranked) {
+		individual.Cost = individual.Fit()
+	})
+	if i.Mutation.Adaptive() {
+		// the one fifth success rule
+		successes := 0
+		for _, individual := range individuals {
+			if individual.Cost < individual.Previous {
+				successes++
+			}
+		}
+		if 5*successes > len(individuals) {
+			i.Sigma *= 1.22
+		} else if 5*successes < len(individuals) {
+			i.Sigma /= 1.22
+		}
+	}
+	sort.Slice(individuals, func(i, j int) bool {
+		return individuals[i].Cost < individuals[j].Cost
+	})
+}
+
+// Breed replaces the individuals after the elites with the children of selected parents
+func (i *Island) Breed() {
+	individuals, rnd := i.Individuals, i.Rnd
+	// the parents that are replaced by children are saved before they are overwritten
+	for j := i.Elitism; j < len(individuals); j++ {
+		parameters, _ := individuals[j].Genes()
+		if i.saved[j] == nil {
+			i.saved[j] = make([][]float32, len(parameters))
+		}
+		for k, p := range parameters {
+			i.saved[j][k] = append(i.saved[j][k][:0], p.X...)
+		}
+	}
+	inherit := func(child, parent int) {
+		parameters, _ := individuals[child].Genes()
+		if parent < i.Elitism {
+			source, _ := individuals[parent].Genes()
+			for k, p := range parameters {
+				copy(p.X, source[k].X)
+			}
+			return
+		}
+		for k, p := range parameters {
+			copy(p.X, i.saved[parent][k])
+		}
+	}
+
+	for index := i.Elitism; index < len(individuals); index += 2 {
+		a, b := i.Select(rnd, individuals), i.Select(rnd, individuals)
+		for tries := 0; a == b && tries < 10; tries++ {
+			b = i.Select(rnd, individuals)
+		}
+		if index+1 == len(individuals) {
+			// the last child of an odd number of children is a copy of its parent
+			inherit(index, a)
+			break
+		}
+		inherit(index, a)
+		inherit(index+1, b)
+		if i.CrossoverRate < 1 && rnd.Float32() >= i.CrossoverRate {
+			continue
+		}
+		i.Cross(rnd, individuals[index], individuals[index+1])
+	}
+}
+
+// Size is the number of individuals of every island
+func (g Genetic) Size() int {
+	if g.Islands > 1 {
+		return g.Islands * g.Population
+	}
+	return g.Population
+}
+
+// Evolve evolves the population until the cost of the best individual has converged
+// The population is split into islands that exchange their best individuals every migration interval
+// The generations are the maximum generations if the cost doesn't converge
+// If the context is done the evolution is abandoned and the error of the context is returned
+func (g Genetic) Evolve(ctx context.Context, rnd *rand.Rand, population []Individual, converged func(cost float32) bool) (generations int, err error) {
+	var islands []*Island
+	if g.Islands <= 1 {
+		islands = []*Island{g.NewIsland(rnd, population)}
+	} else {
+		if len(population) != g.Size() {
+			panic(fmt.Sprintf("the population should have %d individuals", g.Size()))
+		}
+		islands = make([]*Island, g.Islands)
+		for i := range islands {
+			// each island has its own random numbers so that the islands can evolve at the same time
+			source := rand.New(rand.NewSource(rnd.Int63()))
+			islands[i] = g.NewIsland(source, population[i*g.Population:(i+1)*g.Population])
+		}
+	}
+	each := func(f func(island *Island)) {
+		if len(islands) == 1 {
+			f(islands[0])
+			return
+		}
+		var group sync.WaitGroup
+		for _, island := range islands {
+			group.Add(1)
+			go func(island *Island) {
+				defer group.Done()
+				f(island)
+			}(island)
+		}
+		group.Wait()
+	}
+
+	for i := 0; i < g.Generations; i++ {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		each(func(island *Island) {
+			island.Evaluate()
+		})
+		for _, island := range islands {
+			if converged(island.Individuals[0].Cost) {
+				return i, nil
+			}
+		}
+		each(func(island *Island) {
+			island.Breed()
+		})
+		if len(islands) > 1 && g.Interval > 0 && (i+1)%g.Interval == 0 {
+			g.Migrate(islands)
+		}
+	}
+	return g.Generations, nil
+}
+
+// Migrate copies the best individuals of each island over the last individuals of its neighbors
+// The migrants don't replace the elites
+func (g Genetic) Migrate(islands []*Island) {
+	// the migrants are copied before any of them are replaced
+	migrants := make([][][][]float32, len(islands))
+	for i, island := range islands {
+		count := g.Migrants
+		if count > len(island.Individuals) {
+			count = len(island.Individuals)
+		}
+		migrants[i] = make([][][]float32, count)
+		for j := range migrants[i] {
+			parameters, _ := island.Individuals[j].Genes()
+			migrants[i][j] = make([][]float32, len(parameters))
+			for k, p := range parameters {
+				migrants[i][j][k] = append([]float32{}, p.X...)
+			}
+		}
+	}
+	for i, island := range islands {
+		sources := []int{}
+		switch g.Topology {
+		case TopologyRing:
+			sources = append(sources, (i+len(islands)-1)%len(islands))
+		case TopologyFull:
+			for j := range islands {
+				if j != i {
+					sources = append(sources, j)
+				}
+			}
+		default:
+			panic(fmt.Sprintf("unknown topology %d", g.Topology))
+		}
+		index := len(island.Individuals) - 1
+		for _, source := range sources {
+			for _, migrant := range migrants[source] {
+				if index < g.Elitism {
+					break
+				}
+				parameters, _ := island.Individuals[index].Genes()
+				for k, p := range parameters {
+					copy(p.X, migrant[k])
+				}
+				index--
+			}
+		}
+	}
+}
+
+// Select picks a parent from individuals that are sorted by cost
+func (g Genetic) Select(rnd *rand.Rand, individuals []ranked) int {
+	switch g.Selection {
+	case SelectionTruncation:
+		parents := g.Parents
+		if parents == 0 {
+			parents = g.Elitism
+		}
+		if parents <= 0 || parents > len(individuals) {
+			parents = len(individuals)
+		}
+		return rnd.Intn(parents)
+	case SelectionTournament:
+		best := rnd.Intn(len(individuals))
+		for i := 1; i < g.Tournament; i++ {
+			// the individuals are sorted, so the smallest index is the best
+			if contender := rnd.Intn(len(individuals)); contender < best {
+				best = contender
+			}
+		}
+		return best
+	case SelectionRoulette:
+		weights, total := make([]float64, len(individuals)), 0.0
+		for i, individual := range individuals {
+			cost := float64(individual.Cost)
+			if math.IsNaN(cost) || math.IsInf(cost, 0) {
+				continue
+			}
+			weights[i] = 1 / (cost + 1e-6)
+			total += weights[i]
+		}
+		if total == 0 {
+			return rnd.Intn(len(individuals))
+		}
+		spin := rnd.Float64() * total
+		for i, weight := range weights {
+			if spin < weight {
+				return i
+			}
+			spin -= weight
+		}
+		return len(individuals) - 1
+	case SelectionRank:
+		// the best of n individuals has a weight of n and the worst has a weight of 1
+		n := len(individuals)
+		spin := rnd.Intn(n * (n + 1) / 2)
+		for i := 0; i < n; i++ {
+			if spin < n-i {
+				return i
+			}
+			spin -= n - i
+		}
+		return n - 1
+	}
+	panic(fmt.Sprintf("unknown selection %d", g.Selection))
+}
+
+// Cross combines the genomes of two children that start out as copies of their parents
+func (g Genetic) Cross(rnd *rand.Rand, a, b Individual) {
+	parametersA, genomeA := a.Genes()
+	parametersB, genomeB := b.Genes()
+	switch g.Crossover {
+	case CrossoverPair:
+		set := rnd.Intn(len(genomeA))
+		x, y := rnd.Intn(len(genomeA[set])/2), rnd.Intn(len(genomeB[set])/2)
+		genomeA[set][x*2].X, genomeB[set][y*2].X = genomeB[set][y*2].X, genomeA[set][x*2].X
+		genomeA[set][x*2+1].X, genomeB[set][y*2+1].X = genomeB[set][y*2+1].X, genomeA[set][x*2+1].X
+	case CrossoverUniform:
+		for set := range genomeA {
+			for x := 0; x < len(genomeA[set]); x += 2 {
+				if rnd.Intn(2) == 0 {
+					continue
+				}
+				genomeA[set][x].X, genomeB[set][x].X = genomeB[set][x].X, genomeA[set][x].X
+				genomeA[set][x+1].X, genomeB[set][x+1].X = genomeB[set][x+1].X, genomeA[set][x+1].X
+			}
+		}
+	case CrossoverBlend:
+		w := rnd.Float32()
+		for k, p := range parametersA {
+			for l, x := range p.X {
+				y := parametersB[k].X[l]
+				p.X[l], parametersB[k].X[l] = w*x+(1-w)*y, (1-w)*x+w*y
+			}
+		}
+	default:
+		panic(fmt.Sprintf("unknown crossover %d", g.Crossover))
+	}
+}
+
+// Perturb applies the random part of the mutation to an individual
+func (g Genetic) Perturb(rnd *rand.Rand, individual Individual, sigma float32) {
+	parameters, genome := individual.Genes()
+	switch g.Mutation {
+	case MutationGradient:
+	case MutationGaussian, MutationHybrid:
+		for _, p := range parameters {
+			for l := range p.X {
+				p.X[l] += sigma * float32(rnd.NormFloat64())
+			}
+		}
+	case MutationPair:
+		set := rnd.Intn(len(genome))
+		x := 2 * rnd.Intn(len(genome[set])/2)
+		for _, p := range genome[set][x : x+2] {
+			for l := range p.X {
+				p.X[l] += sigma * float32(rnd.NormFloat64())
+			}
+		}
+	case MutationReinit:
+		set := rnd.Intn(len(genome))
+		individual.Reinit(rnd, set, rnd.Intn(len(genome[set])/2))
+	default:
+		panic(fmt.Sprintf("unknown mutation %d", g.Mutation))
+	}
+}
@@ -5,9 +5,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"math"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pointlander/gradient/tf32"
 )
@@ -41,3 +55,1412 @@ func TestDCT(t *testing.T) {
 		})
 	})
 }
+
+func TestCompress(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random32 := func(a, b float32) float32 {
+		return (b-a)*rnd.Float32() + a
+	}
+	input, w := tf32.NewV(4, 2), tf32.NewV(4, 3)
+	parameters := []*tf32.V{&input, &w}
+	m := w.Meta()
+	for i := 0; i < 4; i++ {
+		a, b := tf32.NewV(4, 4), tf32.NewV(4, 3)
+		m = tf32.Add(tf32.Mul(a.Meta(), b.Meta()), m)
+		parameters = append(parameters, &a, &b)
+	}
+	for _, p := range parameters {
+		for i := 0; i < cap(p.X); i++ {
+			p.X = append(p.X, random32(-1, 1))
+		}
+	}
+
+	weights := Compress(m)
+	if len(weights) != 1 {
+		t.Fatal("there should be one compressed matrix", len(weights))
+	}
+	if weights[0].S[0] != 4 || weights[0].S[1] != 3 {
+		t.Fatal("compressed matrix has the wrong shape", weights[0].S)
+	}
+	var expected, actual tf32.V
+	tf32.Mul(m, input.Meta())(func(a *tf32.V) {
+		expected = *a
+	})
+	tf32.Mul(weights[0].Meta(), input.Meta())(func(a *tf32.V) {
+		actual = *a
+	})
+	if !Equal(expected.X, actual.X, 1e-5) {
+		t.Fatal("outputs should be equal", expected.X, actual.X)
+	}
+
+	// the dense product of the compressed weights is the output of the network
+	for _, mode := range []Mode{ModeNormal, ModeInception, ModeDCT} {
+		network := NewNetwork(rnd, IrisDataset{}, Iris.Config, mode, 4, 3, 1)
+		weights, data := Compress(network.Weights...), Iris.Data()
+		for _, datum := range data[:10] {
+			if expected, actual := network.Infer(datum.Input), network.Dense(weights, datum.Input); !Equal(expected, actual, 1e-5) {
+				t.Fatal("dense outputs should be equal", mode, expected, actual)
+			}
+		}
+	}
+}
+
+func TestXOR(t *testing.T) {
+	result := XOR.Run(context.Background(), 1, OptimizerStatic, ModeInception, true, false)
+	if !result.Converged {
+		t.Fatal("xor should converge")
+	}
+	if result.Misses != 0 {
+		t.Fatal("xor should not have misses", result.Misses)
+	}
+}
+
+func TestOptimizers(t *testing.T) {
+	for _, optimizerType := range Optimizers {
+		x := tf32.NewV(4)
+		x.X = append(x.X, 1, -1, .5, -.5)
+		cost := tf32.Sum(tf32.Hadamard(x.Meta(), x.Meta()))
+		optimizer, parameters := optimizerType.New(DefaultHyperparameters(.1)), []*tf32.V{&x}
+		initial := tf32.Gradient(cost).X[0]
+		for i := 0; i < 100; i++ {
+			x.Zero()
+			tf32.Gradient(cost)
+			optimizer.Step(parameters, 1, 1, i)
+		}
+		x.Zero()
+		if final := tf32.Gradient(cost).X[0]; final >= initial {
+			t.Fatal(optimizerType.String(), "should reduce the cost", initial, final)
+		}
+	}
+}
+
+func TestConfigLoad(t *testing.T) {
+	file, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(`{"depth": 8, "eta": 0.25, "genetic": {"selection": "rank", "population": 20}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	config := XOR.Config
+	err = config.Load(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Depth != 8 || config.Eta != .25 {
+		t.Fatal("config should be overridden", config.Depth, config.Eta)
+	}
+	if config.Width != XOR.Width || config.Alpha != XOR.Alpha {
+		t.Fatal("config should keep its defaults", config.Width, config.Alpha)
+	}
+	if config.Genetic.Selection != SelectionRank || config.Genetic.Population != 20 ||
+		config.Genetic.Elitism != XOR.Genetic.Elitism {
+		t.Fatal("genetic config should be overridden", config.Genetic)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	data := IrisDataset{}.Data()
+	train, validation, test, err := Split(rand.New(rand.NewSource(1)), data, .2, .2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(train) != 90 || len(validation) != 30 || len(test) != 30 {
+		t.Fatal("split has the wrong sizes", len(train), len(validation), len(test))
+	}
+	counts := make([]int, 3)
+	for _, datum := range test {
+		counts[IrisDataset{}.Label(datum.Output)]++
+	}
+	for _, count := range counts {
+		if count != 10 {
+			t.Fatal("split should be stratified", counts)
+		}
+	}
+	for _, fractions := range [][2]float64{{.6, .6}, {.5, .5}, {-.1, .2}} {
+		_, _, _, err := Split(rand.New(rand.NewSource(1)), data, fractions[0], fractions[1])
+		if err == nil {
+			t.Fatal("split should fail for", fractions)
+		}
+	}
+	// a class of one datum rounds to the test set
+	_, _, _, err = Split(rand.New(rand.NewSource(1)), data[:1], 0, .5)
+	if err == nil {
+		t.Fatal("split should fail without training data")
+	}
+
+	// each output of a regression is a class of its own, so its split is shuffled
+	regression := make([]Datum, 10)
+	for i := range regression {
+		regression[i] = Datum{Input: []float32{float32(i)}, Output: []float32{float32(i) / 10}}
+	}
+	if _, validation, test, _ := Split(rand.New(rand.NewSource(1)), regression, .2, .2); len(validation) != 0 || len(test) != 0 {
+		t.Fatal("a stratified split of a regression should be empty", len(validation), len(test))
+	}
+	experiment := XOR
+	experiment.Validation, experiment.Test = .2, .2
+	train, validation, test, err = experiment.Split(rand.New(rand.NewSource(1)), regression)
+	if err != nil || len(train) != 6 || len(validation) != 2 || len(test) != 2 {
+		t.Fatal("the split of a regression should be shuffled", len(train), len(validation), len(test), err)
+	}
+}
+
+func TestRunSplitError(t *testing.T) {
+	experiment := Iris
+	experiment.Validation, experiment.Test = .5, .5
+	result := experiment.Run(context.Background(), 1, OptimizerStatic, ModeNormal, true, false)
+	if result.Error == "" || result.Converged || result.Canceled {
+		t.Fatal("a split without training data should fail the run", result.Error)
+	}
+
+	// the failed runs aren't scored
+	experiment.Seeds, experiment.Output = 2, &Output{}
+	if err := experiment.RunRepeated(context.Background(), []Mode{ModeNormal}, false); err == nil {
+		t.Fatal("a split without training data should fail the experiment")
+	}
+	if len(experiment.Output.Results) != 0 || len(experiment.Output.Statistics) != 0 {
+		t.Fatal("a failed experiment shouldn't have statistics", len(experiment.Output.Results), len(experiment.Output.Statistics))
+	}
+	if err := experiment.RunOnce(context.Background(), 1, []Mode{ModeNormal}, false); err == nil {
+		t.Fatal("a split without training data should fail the single runs")
+	}
+}
+
+func TestConfusion(t *testing.T) {
+	confusion := Confusion{
+		{3, 1},
+		{0, 4},
+	}
+	if accuracy := confusion.Accuracy(); accuracy != 7.0/8.0 {
+		t.Fatal("accuracy should be 7/8", accuracy)
+	}
+	if precision := confusion.Precision(1); precision != 4.0/5.0 {
+		t.Fatal("precision should be 4/5", precision)
+	}
+	if recall := confusion.Recall(0); recall != 3.0/4.0 {
+		t.Fatal("recall should be 3/4", recall)
+	}
+	confusion.Add(Confusion{{1, 0}, {1, 2}})
+	if confusion[0][0] != 4 || confusion[1][0] != 1 || confusion.Total() != 12 {
+		t.Fatal("confusion matrices should add", confusion)
+	}
+}
+
+func TestSchedules(t *testing.T) {
+	h := DefaultHyperparameters(.1)
+	h.StepSize, h.Period, h.Gamma, h.Patience = 10, 10, .5, 2
+	if rate := ScheduleStep.New(h, 100).Rate(10, nil); rate != .5 {
+		t.Fatal("step should decay after step size epochs", rate)
+	}
+	if rate := ScheduleExponential.New(h, 100).Rate(5, nil); !Equal([]float32{rate}, []float32{sqrt(.5)}, 1e-6) {
+		t.Fatal("exponential should decay continuously", rate)
+	}
+	cosine := ScheduleCosine.New(h, 100)
+	if rate := cosine.Rate(10, nil); rate != 1 {
+		t.Fatal("cosine should restart after the period", rate)
+	}
+	if rate := cosine.Rate(20, nil); rate != h.MinRate+(1-h.MinRate)/2 {
+		t.Fatal("cosine period should grow after a restart", rate)
+	}
+	if rate := ScheduleOneCycle.New(h, 100).Rate(30, nil); rate != 1 {
+		t.Fatal("one cycle should peak at 30%", rate)
+	}
+	plateau := SchedulePlateau.New(h, 100)
+	if rate := plateau.Rate(3, []float32{3, 2, 2}); rate != 1 {
+		t.Fatal("plateau should wait for patience epochs", rate)
+	}
+	if rate := plateau.Rate(4, []float32{3, 2, 2, 2}); rate != .5 {
+		t.Fatal("plateau should decay after patience epochs", rate)
+	}
+	h.Warmup = 4
+	if rate := ScheduleWarmup.New(h, 100).Rate(0, nil); rate != .25 {
+		t.Fatal("warmup should start small", rate)
+	}
+}
+
+func TestScheduleCheck(t *testing.T) {
+	invalid := []struct {
+		schedule ScheduleType
+		change   func(h *Hyperparameters)
+	}{
+		{ScheduleStep, func(h *Hyperparameters) { h.StepSize = 0 }},
+		{ScheduleExponential, func(h *Hyperparameters) { h.StepSize = -1 }},
+		{ScheduleCosine, func(h *Hyperparameters) { h.Period = 0 }},
+		{ScheduleCosine, func(h *Hyperparameters) { h.PeriodMult = 0 }},
+		{ScheduleWarmup, func(h *Hyperparameters) { h.Warmup = 0 }},
+		{ScheduleConstant, func(h *Hyperparameters) { h.Warmup = -1 }},
+		{SchedulePlateau, func(h *Hyperparameters) { h.Patience = 0 }},
+		{SchedulePlateau, func(h *Hyperparameters) { h.Gamma = 0 }},
+		{ScheduleStep, func(h *Hyperparameters) { h.Gamma = 1.5 }},
+		{ScheduleExponential, func(h *Hyperparameters) { h.Gamma = -.5 }},
+	}
+	for _, test := range invalid {
+		h := DefaultHyperparameters(.1)
+		test.change(&h)
+		if err := test.schedule.Check(h); err == nil {
+			t.Fatal("the schedule should be invalid", test.schedule, h)
+		}
+		config := XOR.Config
+		config.Hyperparameters = h
+		config.Schedule = test.schedule
+		if err := config.Validate(); err == nil {
+			t.Fatal("the config should be invalid", test.schedule, h)
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("creating the schedule should panic", test.schedule, h)
+				}
+			}()
+			test.schedule.New(h, 100)
+		}()
+	}
+	h := DefaultHyperparameters(.1)
+	h.StepSize, h.Period, h.PeriodMult = 0, 0, 0
+	if err := ScheduleConstant.Check(h); err != nil {
+		t.Fatal("the constant schedule doesn't use the step size or period", err)
+	}
+	h.Period, h.PeriodMult = 10, 1
+	if rate := ScheduleCosine.New(h, 100).Rate(25, nil); rate != ScheduleCosine.New(h, 100).Rate(5, nil) {
+		t.Fatal("a period mult of 1 should repeat the period", rate)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	directory, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	runs := []struct {
+		Experiment
+		optimizer OptimizerType
+		context   bool
+	}{
+		{XOR, OptimizerMomentum, true},
+		{Iris, OptimizerAdam, false},
+	}
+	for _, run := range runs {
+		expected := run.Run(context.Background(), 1, run.optimizer, ModeInception, false, run.context)
+
+		interrupted := run.Experiment
+		interrupted.Checkpoints, interrupted.Interval, interrupted.Epochs = directory, 5, 20
+		partial := interrupted.Run(context.Background(), 1, run.optimizer, ModeInception, false, run.context)
+		if len(partial.Costs) != 20 {
+			t.Fatal("training should stop after 20 epochs", len(partial.Costs))
+		}
+
+		resumed := run.Experiment
+		resumed.Checkpoints, resumed.Interval = directory, 5
+		actual := resumed.Run(context.Background(), 1, run.optimizer, ModeInception, false, run.context)
+		if !expected.Converged || len(actual.Costs) != len(expected.Costs) || !Equal(actual.Costs, expected.Costs, 0) {
+			t.Fatal("resumed training should be the same", len(actual.Costs), len(expected.Costs))
+		}
+	}
+
+	checkpoint, err := LoadCheckpoint(filepath.Join(directory, "iris_inception_adam_false_false_1.gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	network, err := checkpoint.Network()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataset := IrisDataset{}
+	data := dataset.Data()
+	if output := network.Infer(data[0].Input); dataset.Label(output) != dataset.Label(data[0].Output) {
+		t.Fatal("loaded network should classify the first iris", output)
+	}
+}
+
+func TestServer(t *testing.T) {
+	directory, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	for _, experiment := range []Experiment{XOR, Iris} {
+		experiment.Checkpoints, experiment.Epochs = directory, 20
+		result := experiment.Run(context.Background(), 1, OptimizerAdam, ModeInception, true, false)
+		name := filepath.Join(directory, fmt.Sprintf("%s_inception_adam_true_false_1.gob", experiment.Name()))
+		checkpoint, err := LoadCheckpoint(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		model, err := checkpoint.Model()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = model.Save(name + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		model, err = LoadModel(name + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if model.Epochs != len(result.Costs) || len(model.Weights) != 4 {
+			t.Fatal("model should be the compressed network of the checkpoint", model.Epochs, len(model.Weights))
+		}
+		server, err := NewServer(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		network, err := checkpoint.Network()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request := func(method, path, body string) (int, map[string]interface{}) {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+			response := map[string]interface{}{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			return recorder.Code, response
+		}
+		if code, response := request("GET", "/health", ""); code != http.StatusOK || response["status"] != "ok" {
+			t.Fatal("server should be healthy", code, response)
+		}
+		if code, response := request("GET", "/model", ""); code != http.StatusOK || response["dataset"] != experiment.Name() ||
+			response["classifier"] != (experiment.Name() == "iris") {
+			t.Fatal("wrong model metadata", code, response)
+		}
+		if code, _ := request("GET", "/predict", ""); code != http.StatusMethodNotAllowed {
+			t.Fatal("predict should only accept POST", code)
+		}
+		if code, _ := request("POST", "/predict", `{"input":[1]}`); code != http.StatusBadRequest {
+			t.Fatal("an input of the wrong size should be rejected", code)
+		}
+
+		data := experiment.Data()
+		inputs, _ := json.Marshal(PredictRequest{Inputs: [][]float32{data[0].Input, data[len(data)-1].Input}})
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest("POST", "/predict", bytes.NewReader(inputs)))
+		var prediction Prediction
+		if err := json.Unmarshal(recorder.Body.Bytes(), &prediction); err != nil {
+			t.Fatal(err)
+		}
+		if len(prediction.Outputs) != 2 {
+			t.Fatal("there should be an output for each input", recorder.Body.String())
+		}
+		for i, datum := range []Datum{data[0], data[len(data)-1]} {
+			if expected := network.Infer(datum.Input); !Equal(prediction.Outputs[i], expected, 1e-5) {
+				t.Fatal("the compressed model should have the outputs of the network", prediction.Outputs[i], expected)
+			}
+		}
+		if classifier := experiment.Name() == "iris"; classifier != (len(prediction.Probabilities) == 2) ||
+			classifier != (len(prediction.Classes) == 2) || classifier != (len(prediction.Labels) == 2) {
+			t.Fatal("only a classifier should predict probabilities and classes", recorder.Body.String())
+		}
+		for i, label := range prediction.Labels {
+			if label != model.Labels[prediction.Classes[i]] || !strings.HasPrefix(label, "Iris-") {
+				t.Fatal("the labels should be the species of the classes", prediction.Labels, model.Labels)
+			}
+		}
+
+		// the predictions don't change the weights that the requests share
+		var group sync.WaitGroup
+		bodies := make([]string, 8)
+		for i := range bodies {
+			group.Add(1)
+			go func(i int) {
+				defer group.Done()
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, httptest.NewRequest("POST", "/predict", bytes.NewReader(inputs)))
+				bodies[i] = recorder.Body.String()
+			}(i)
+		}
+		group.Wait()
+		for _, body := range bodies {
+			if body != recorder.Body.String() {
+				t.Fatal("concurrent predictions should be the same", body, recorder.Body.String())
+			}
+		}
+		for _, p := range server.Network.Parameters {
+			for _, d := range p.D {
+				if d != 0 {
+					t.Fatal("predictions shouldn't compute partial derivatives")
+				}
+			}
+		}
+	}
+}
+
+func TestModelRegression(t *testing.T) {
+	// the dataset of the model isn't registered and the network is built from the model alone
+	model := &Model{
+		Version:     ModelVersion,
+		Dataset:     "unregistered",
+		In:          2,
+		Out:         2,
+		Task:        TaskRegression,
+		Sizes:       []int{2, 2},
+		Activations: Activations{ActivationIdentity},
+		Loss:        LossQuadratic,
+		Weights: []Tensor{
+			{Shape: []int{2, 2}, Values: []float32{1, 2, 3, 4}},
+			{Shape: []int{2, 1}, Values: []float32{1, -1}},
+		},
+	}
+	server, err := NewServer(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest("POST", "/predict", strings.NewReader(`{"input":[1,1]}`)))
+	var prediction Prediction
+	if err := json.Unmarshal(recorder.Body.Bytes(), &prediction); err != nil {
+		t.Fatal(err)
+	}
+	if len(prediction.Outputs) != 1 || !Equal(prediction.Outputs[0], []float32{4, 6}, 1e-5) {
+		t.Fatal("the outputs should be the product of the weights and the input plus the bias", recorder.Body.String())
+	}
+	if prediction.Probabilities != nil || prediction.Classes != nil || prediction.Labels != nil {
+		t.Fatal("a regression with many outputs shouldn't predict classes", recorder.Body.String())
+	}
+
+	model.Activations = nil
+	if _, err := model.Network(); err == nil {
+		t.Fatal("a model without the activations of its layers should be rejected")
+	}
+	model.Activations, model.Task = Activations{ActivationSoftmax}, TaskClassification
+	if _, err := model.Network(); err == nil {
+		t.Fatal("a classification without labels should be rejected")
+	}
+}
+
+func TestCSV(t *testing.T) {
+	directory, err := ioutil.TempDir("", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	name := filepath.Join(directory, "xor.tsv")
+	err = ioutil.WriteFile(name, []byte("a\tb\ty\n0\t0\tno\n2\t0\tyes\n0\t4\tyes\n2\t4\tno\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataset, err := LoadCSV(name, CSVOptions{Header: true, Labels: []string{"y"}, Normalization: NormalizationMinMax})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataset.Name() != "xor" || dataset.Classes() != 2 {
+		t.Fatal("dataset should be xor with 2 classes", dataset.Name(), dataset.Classes())
+	}
+	if err := Register(dataset); err == nil || Datasets["xor"] != (XORDataset{}) {
+		t.Fatal("a csv dataset shouldn't replace the xor dataset", err)
+	}
+	data := dataset.Data()
+	if !Equal(data[3].Input, []float32{2, 4}, 0) || !Equal(data[3].Output, []float32{1, 0}, 0) {
+		t.Fatal("unexpected datum", data[3])
+	}
+	// the scaling of the training data is applied to the other data
+	train, others := data[:2], data[2:]
+	dataset.Normalize(train, others)
+	if !Equal(train[1].Input, []float32{1, 0}, 0) || !Equal(others[1].Input, []float32{1, 4}, 0) {
+		t.Fatal("the data should be normalized with the training data", train, others)
+	}
+	if data := dataset.Data(); !Equal(data[3].Input, []float32{2, 4}, 0) {
+		t.Fatal("normalizing shouldn't change the dataset", data[3])
+	}
+	if dataset.Label(data[1].Output) != 1 || dataset.Label([]float32{.2, .8}) != 1 {
+		t.Fatal("label should be yes")
+	}
+
+	_, err = LoadCSV(name, CSVOptions{Header: true, Features: []string{"0", "b"}, Labels: []string{"a"},
+		Target: TargetNumeric, Normalization: NormalizationZScore})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadCSV(name, CSVOptions{Header: true, Labels: []string{"a"}, Target: TargetNumeric})
+	if err == nil {
+		t.Fatal("the y column should not be numeric")
+	}
+
+	dataset, err = LoadCSV(name, CSVOptions{Header: true, Features: []string{"a", "b"}, Labels: []string{"a"},
+		Target: TargetNumeric, Normalization: NormalizationZScore})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = dataset.Data()
+	dataset.Normalize(data)
+	if !Equal(data[0].Input, []float32{-1, -1}, 1e-6) || !Equal(data[1].Output, []float32{2}, 0) {
+		t.Fatal("unexpected datum", data[0], data[1])
+	}
+	if dataset.Classes() != 2 || dataset.Label([]float32{1.5}) != 1 {
+		t.Fatal("numeric labels should be the closest value")
+	}
+
+	experiment := Iris
+	experiment.Dataset, _ = LoadCSV(name, CSVOptions{Header: true, Labels: []string{"y"}, Normalization: NormalizationMinMax})
+	experiment.BatchSize, experiment.BatchThreshold = 4, .1
+	result := experiment.Run(context.Background(), 1, OptimizerMomentum, ModeInception, true, false)
+	if !result.Converged || result.Misses != 0 {
+		t.Fatal("csv xor should converge", len(result.Costs), result.Misses)
+	}
+}
+
+func TestLayers(t *testing.T) {
+	config := XOR.Config
+	config.Layers, config.Factorized, config.Depth = []int{4, 3}, []int{1}, 2
+	network := NewNetwork(rand.New(rand.NewSource(1)), XORDataset{}, config, ModeInception, 2, 1, 4)
+	// 3 layers of weights and biases and 2 factor pairs for the weights and bias of the second layer
+	if len(network.Weights) != 6 || len(network.Parameters) != 6+2*2*2 {
+		t.Fatal("wrong number of parameters", len(network.Weights), len(network.Parameters))
+	}
+	network = NewNetwork(rand.New(rand.NewSource(1)), XORDataset{}, config, ModeDCT, 2, 1, 4)
+	if len(network.Parameters) != 6+2 || len(network.Zero) != 4 {
+		t.Fatal("wrong number of dct parameters", len(network.Parameters), len(network.Zero))
+	}
+
+	experiment := XOR
+	experiment.Layers = []int{3, 3}
+	for _, mode := range []Mode{ModeNormal, ModeInception} {
+		result := experiment.Run(context.Background(), 2, OptimizerStatic, mode, false, false)
+		if !result.Converged || result.Misses != 0 {
+			t.Fatal("deep xor should converge", mode, len(result.Costs), result.Misses)
+		}
+	}
+}
+
+func TestActivations(t *testing.T) {
+	inputs := []float32{-3, -.5, .25, 2}
+	for _, activation := range ActivationFunctions {
+		if activation == ActivationSoftmax {
+			continue
+		}
+		x := tf32.NewV(len(inputs))
+		x.X = append(x.X, inputs...)
+		tf32.Gradient(tf32.Sum(activation.Apply(x.Meta())))
+		for i, input := range inputs {
+			f := func(x float32) float32 {
+				v := tf32.NewV(1)
+				v.X = append(v.X, x)
+				var y float32
+				activation.Apply(v.Meta())(func(a *tf32.V) {
+					y = a.X[0]
+				})
+				return y
+			}
+			h := float32(1e-3)
+			numeric := (f(input+h) - f(input-h)) / (2 * h)
+			if math.Abs(float64(numeric-x.D[i])) > 1e-2 {
+				t.Fatal("wrong derivative", activation, input, numeric, x.D[i])
+			}
+		}
+	}
+
+	var activations Activations
+	err := activations.Set("relu,default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activations.Layer(0) != ActivationReLU || activations.Layer(1) != ActivationDefault ||
+		activations.Layer(5) != ActivationDefault || activations.String() != "relu,default" {
+		t.Fatal("wrong activations", activations)
+	}
+
+	experiment := XOR
+	experiment.Activations = Activations{ActivationTanH}
+	result := experiment.Run(context.Background(), 2, OptimizerStatic, ModeNormal, false, false)
+	if !result.Converged || result.Misses != 0 {
+		t.Fatal("tanh xor should converge", len(result.Costs), result.Misses)
+	}
+}
+
+func TestLosses(t *testing.T) {
+	outputs, targets := []float32{.2, .7, .1, .9, .05, .5}, []float32{0, 1, 0, 1, 0, 0}
+	for _, loss := range Losses[1:] {
+		a, b := tf32.NewV(3, 2), tf32.NewV(3, 2)
+		a.X, b.X = append(a.X, outputs...), append(b.X, targets...)
+		cost := tf32.Gradient(loss.Cost(a.Meta(), b.Meta())).X[0]
+		if cost <= 0 {
+			t.Fatal("cost should be positive", loss, cost)
+		}
+		for i := range outputs {
+			f := func(x float32) float32 {
+				c := tf32.NewV(3, 2)
+				c.X = append(c.X, outputs...)
+				c.X[i] = x
+				var cost float32
+				loss.Cost(c.Meta(), b.Meta())(func(a *tf32.V) {
+					cost = a.X[0]
+				})
+				return cost
+			}
+			h := float32(1e-3)
+			numeric := (f(outputs[i]+h) - f(outputs[i]-h)) / (2 * h)
+			if math.Abs(float64(numeric-a.D[i])) > 1e-2 {
+				t.Fatal("wrong derivative", loss, i, numeric, a.D[i])
+			}
+		}
+	}
+
+	config := Config{Criterion: CriterionMean}
+	if !config.Converged(OptimizerStatic, false, .03, 4, LossMSE) || config.Converged(OptimizerStatic, false, .05, 4, LossMSE) {
+		t.Fatal("the mean cost should be compared to the threshold of the loss")
+	}
+	config.LossThreshold = .02
+	if !config.Converged(OptimizerStatic, false, .07, 4, LossMSE) || config.Converged(OptimizerStatic, false, .09, 4, LossMSE) {
+		t.Fatal("the loss threshold should replace the threshold of the loss")
+	}
+	config = XOR.Config
+	for _, optimizer := range Optimizers {
+		adam := optimizer == OptimizerRMSProp || optimizer == OptimizerAdam ||
+			optimizer == OptimizerAdamW || optimizer == OptimizerAMSGrad
+		if converged := config.Converged(optimizer, false, .05, 4, LossQuadratic); converged != adam {
+			t.Fatal("the adam threshold should apply to the optimizers with the learning rate rate", optimizer)
+		}
+		if config.Converged(optimizer, true, .05, 1, LossQuadratic) {
+			t.Fatal("the batch threshold should apply to every optimizer", optimizer)
+		}
+	}
+	config = Iris.Config
+	if !config.Converged(OptimizerStatic, true, 1.2, 15, LossQuadratic) || config.Converged(OptimizerStatic, true, 1.4, 15, LossQuadratic) {
+		t.Fatal("the batch threshold should be the threshold divided by the batch size")
+	}
+	config.BatchSize = 26
+	if !config.Converged(OptimizerStatic, true, .4, 6, LossQuadratic) || config.Converged(OptimizerStatic, true, .6, 6, LossQuadratic) {
+		t.Fatal("the batch threshold should follow the batch size")
+	}
+
+	for _, loss := range []Loss{LossCategoricalCrossEntropy, LossMSE} {
+		experiment := Iris
+		experiment.Loss, experiment.Criterion = loss, CriterionMean
+		experiment.Activations = Activations{ActivationDefault, ActivationSoftmax}
+		result := experiment.Run(context.Background(), 1, OptimizerStatic, ModeInception, false, false)
+		if !result.Converged || len(result.Costs) < 2 {
+			t.Fatal("iris should converge", loss, len(result.Costs))
+		}
+	}
+}
+
+func TestInitializers(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, size := range [][2]int{{4, 4}, {3, 5}, {5, 3}} {
+		width, height := size[0], size[1]
+		m := Orthogonal(rnd, width, height, 1)
+		// the smaller of the rows and columns are orthonormal
+		vectors, length, at := height, width, func(i, j int) float32 { return m[i*width+j] }
+		if height > width {
+			vectors, length, at = width, height, func(i, j int) float32 { return m[j*width+i] }
+		}
+		for i := 0; i < vectors; i++ {
+			for j := 0; j < vectors; j++ {
+				dot := float32(0)
+				for k := 0; k < length; k++ {
+					dot += at(i, k) * at(j, k)
+				}
+				expected := float32(0)
+				if i == j {
+					expected = 1
+				}
+				if math.Abs(float64(dot-expected)) > 1e-5 {
+					t.Fatal("matrix should be orthogonal", width, height, i, j, dot)
+				}
+			}
+		}
+	}
+
+	for _, initializer := range Initializers {
+		p := tf32.NewV(8, 6)
+		initializer.Initialize(rnd, &p, 1)
+		if len(p.X) != 48 {
+			t.Fatal("all of the values should be initialized", initializer)
+		}
+		if initializer == InitializerXavier {
+			for _, value := range p.X {
+				if value < -.655 || value > .655 {
+					t.Fatal("xavier should be within sqrt(6/14)", value)
+				}
+			}
+		}
+	}
+
+	config := XOR.Config
+	config.FactorBInit, config.WeightInit = InitializerZeros, InitializerXavier
+	data := XORDataset{}.Data()
+	normal := NewNetwork(rand.New(rand.NewSource(1)), XORDataset{}, config, ModeNormal, 2, 1, 1)
+	inception := NewNetwork(rand.New(rand.NewSource(1)), XORDataset{}, config, ModeInception, 2, 1, 1)
+	for _, datum := range data {
+		if a, b := normal.Infer(datum.Input), inception.Infer(datum.Input); !Equal(a, b, 1e-6) {
+			t.Fatal("inception should start out as the normal network", a, b)
+		}
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	var file OutputFile
+	for _, name := range []string{"results.json", "results.JSONL", "dir/results.csv"} {
+		if err := file.Set(name); err != nil || file.String() != name {
+			t.Fatal("the output file should be set", name, err)
+		}
+	}
+	for _, name := range []string{"results.txt", "results", "results.json.gz"} {
+		if err := file.Set(name); err == nil {
+			t.Fatal("the output format should be unknown", name)
+		}
+	}
+}
+
+func TestOutput(t *testing.T) {
+	directory, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	output := &Output{}
+	result := XOR.Run(context.Background(), 1, OptimizerStatic, ModeInception, false, false)
+	output.AddResult(result)
+	output.AddResult(Result{Dataset: "xor", Seed: 2, Costs: []float32{1, float32(math.NaN())},
+		Confusion: Confusion{{2, 0}, {1, 0}}})
+	output.AddStatistics("xor", Statistics{Mode: ModeInception, Count: 1})
+	output.AddGenerations("xor", 1, 10)
+
+	name := filepath.Join(directory, "results.json")
+	err = output.Save(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		Results []struct {
+			Seed      int64
+			Mode      string
+			Converged bool
+			Epochs    int
+			Costs     []*float32
+			Precision []*float64
+			Recall    []*float64
+			Confusion Confusion
+		}
+		Statistics []struct {
+			AverageEpochs *float64 `json:"average_epochs"`
+		}
+		Generations []GenerationsRecord
+	}
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Results) != 2 || saved.Results[0].Mode != "inception" || !saved.Results[0].Converged ||
+		saved.Results[0].Epochs != len(result.Costs) || len(saved.Results[0].Costs) != len(result.Costs) {
+		t.Fatal("wrong results", saved.Results)
+	}
+	if saved.Results[1].Costs[1] != nil || saved.Statistics[0].AverageEpochs != nil ||
+		saved.Results[1].Precision[1] != nil {
+		t.Fatal("NaN should be null")
+	}
+	if r := saved.Results[1]; *r.Precision[0] != 2.0/3.0 || *r.Recall[0] != 1 || *r.Recall[1] != 0 ||
+		len(r.Confusion) != 2 || r.Confusion[1][0] != 1 {
+		t.Fatal("wrong precision, recall or confusion", r.Precision, r.Recall, r.Confusion)
+	}
+	if len(saved.Generations) != 1 || saved.Generations[0].Generations != 10 {
+		t.Fatal("wrong generations", saved.Generations)
+	}
+
+	name = filepath.Join(directory, "results.jsonl")
+	err = output.Save(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 4 ||
+		!strings.HasPrefix(lines[3], `{"type":"generations"`) {
+		t.Fatal("wrong lines", lines)
+	}
+
+	name = filepath.Join(directory, "results.csv")
+	err = output.Save(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"results.csv", "results_statistics.csv", "results_generations.csv"} {
+		file, err := os.Open(filepath.Join(directory, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(file).ReadAll()
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) < 2 || len(rows[0]) != len(rows[1]) {
+			t.Fatal("wrong rows", name, rows)
+		}
+	}
+
+	if err := output.Save(filepath.Join(directory, "results.txt")); err == nil {
+		t.Fatal("txt should be an unknown format")
+	}
+}
+
+func TestStatistics(t *testing.T) {
+	values := []float64{4, 1, 3, 2, 5}
+	if Mean(values) != 3 || Median(values) != 3 || Percentile(values, 25) != 2 || Percentile(values, 100) != 5 {
+		t.Fatal("wrong mean, median or percentile")
+	}
+	if math.Abs(StdDev(values)-math.Sqrt(2.5)) > 1e-9 {
+		t.Fatal("wrong standard deviation", StdDev(values))
+	}
+	lower, upper := Bootstrap(rand.New(rand.NewSource(1)), values, 1000, .95)
+	if lower > 3 || upper < 3 || lower < 1 || upper > 5 {
+		t.Fatal("wrong confidence interval", lower, upper)
+	}
+
+	// the example from https://en.wikipedia.org/wiki/Wilcoxon_signed-rank_test
+	a := []float64{125, 115, 130, 140, 140, 115, 140, 125, 140, 135}
+	b := []float64{110, 122, 125, 120, 140, 124, 123, 137, 135, 145}
+	w, p, n := Wilcoxon(a, b)
+	if w != 18 || n != 9 || p < .5 || p > .7 {
+		t.Fatal("wrong wilcoxon test", w, p, n)
+	}
+	a, b = make([]float64, 20), make([]float64, 20)
+	for i := range a {
+		a[i], b[i] = float64(100+i), float64(10+i)
+	}
+	if _, p, _ := Wilcoxon(a, b); p > .001 {
+		t.Fatal("the difference should be significant", p)
+	}
+
+	normal, inception := Statistics{Mode: ModeNormal}, Statistics{Mode: ModeInception}
+	for i := range a {
+		normal.Aggregate(Result{Seed: int64(i), Costs: make([]float32, int(a[i])), Converged: true})
+		inception.Aggregate(Result{Seed: int64(i), Costs: make([]float32, int(b[i])), Converged: i > 0})
+	}
+	if inception.Converged != 19 || inception.Runs[0] != 10 {
+		t.Fatal("wrong aggregation", inception.Converged, inception.Runs[0])
+	}
+	comparison := Compare(&normal, &inception)
+	if comparison.N != 20 || comparison.P > .001 || comparison.Speedup < 5 {
+		t.Fatal("wrong comparison", comparison)
+	}
+	if empty := (Statistics{Count: 1}); empty.AverageEpochs() != 0 {
+		t.Fatal("average epochs should be 0 without convergence")
+	}
+}
+
+func TestPlots(t *testing.T) {
+	nan := float32(math.NaN())
+	quantiles := CostQuantiles([][]float32{{4, 3, 2}, {6, nan}, {8, 1, 0, 0}}, 0, 50, 100)
+	expected := [][]float64{{4, 1, 0, 0}, {6, 2, 1, 1}, {8, 3, 2, 2}}
+	for i := range expected {
+		for j, value := range expected[i] {
+			if quantiles[i][j] != value {
+				t.Fatal("wrong quantiles", quantiles, expected)
+			}
+		}
+	}
+	if quantiles := CostQuantiles([][]float32{{nan}}, 50); !math.IsNaN(quantiles[0][0]) {
+		t.Fatal("the quantile of an epoch without finite costs should be NaN", quantiles)
+	}
+
+	directory, err := ioutil.TempDir("", "plots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	experiment := XOR
+	experiment.Epochs = 1000
+	statistics := []Statistics{}
+	for _, mode := range []Mode{ModeNormal, ModeInception} {
+		s := Statistics{Mode: mode, Optimizer: OptimizerAdam, Batch: 4}
+		for seed := int64(1); seed <= 3; seed++ {
+			result := experiment.Run(context.Background(), seed, OptimizerAdam, mode, true, false)
+			s.Aggregate(result)
+			s.Costs = append(s.Costs, result.Costs)
+		}
+		statistics = append(statistics, s)
+	}
+	for _, log := range []bool{false, true} {
+		costs, epochs := filepath.Join(directory, "costs.png"), filepath.Join(directory, "epochs.png")
+		if err := PlotCosts(costs, statistics, log); err != nil {
+			t.Fatal(err)
+		}
+		if err := PlotEpochs(epochs, "xor epochs", statistics, log); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{costs, epochs} {
+			if info, err := os.Stat(file); err != nil || info.Size() == 0 {
+				t.Fatal("the plot should be saved", file, err)
+			}
+			os.Remove(file)
+		}
+	}
+	if err := PlotEpochs(filepath.Join(directory, "none.png"), "none", []Statistics{{}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(directory, "none.png")); !os.IsNotExist(err) {
+		t.Fatal("there shouldn't be a box plot without any convergence")
+	}
+}
+
+func TestPool(t *testing.T) {
+	var mutex sync.Mutex
+	running, max, count := 0, 0, 0
+	pool := NewPool(3)
+	for i := 0; i < 32; i++ {
+		pool.Submit(func() {
+			mutex.Lock()
+			running++
+			if running > max {
+				max = running
+			}
+			mutex.Unlock()
+			time.Sleep(time.Millisecond)
+			mutex.Lock()
+			running--
+			count++
+			mutex.Unlock()
+		})
+	}
+	pool.Close()
+	if count != 32 {
+		t.Fatalf("%d tasks should have run", count)
+	}
+	if max > 3 {
+		t.Fatalf("%d tasks shouldn't run at once", max)
+	}
+
+	run := func(workers int) []Record {
+		experiment := XOR
+		experiment.Seeds, experiment.Epochs, experiment.Workers = 3, 20, workers
+		experiment.Output = &Output{}
+		if err := experiment.RunRepeated(context.Background(), []Mode{ModeNormal, ModeInception}, false); err != nil {
+			t.Fatal(err)
+		}
+		return experiment.Output.Results
+	}
+	serial, parallel := run(1), run(4)
+	if len(serial) != len(parallel) || len(serial) != 3*2*2*len(Optimizers) {
+		t.Fatal("wrong number of results", len(serial), len(parallel))
+	}
+	for i := range serial {
+		a, b := serial[i], parallel[i]
+		if a.Seed != int64(i%3+1) || a.Seed != b.Seed || a.Mode != b.Mode || a.Optimizer != b.Optimizer ||
+			a.Batch != b.Batch || a.Epochs != b.Epochs {
+			t.Fatal("results should be in the same order", i, a.Seed, b.Seed)
+		}
+	}
+}
+
+func TestDistributed(t *testing.T) {
+	coordinator, err := NewCoordinator("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(coordinator *Coordinator) []Record {
+		experiment := XOR
+		experiment.Seeds, experiment.Epochs, experiment.Coordinator = 3, 20, coordinator
+		// the local workers don't limit the runs that are queued with the coordinator
+		experiment.Workers = 1
+		experiment.Output = &Output{}
+		if err := experiment.RunRepeated(context.Background(), []Mode{ModeNormal, ModeInception}, true); err != nil {
+			t.Error(err)
+		}
+		return experiment.Output.Results
+	}
+	local := run(nil)
+
+	errs, queued := make(chan error, 2), make(chan error, 1)
+	go func() {
+		// the workers connect after every run is queued
+		deadline, err := time.Now().Add(10*time.Second), error(nil)
+		for {
+			coordinator.mutex.Lock()
+			length := len(coordinator.queue)
+			coordinator.mutex.Unlock()
+			if length == len(local) {
+				break
+			} else if time.Now().After(deadline) {
+				err = fmt.Errorf("%d of %d runs are queued", length, len(local))
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		queued <- err
+		for i := 0; i < 2; i++ {
+			worker := Worker{Name: fmt.Sprintf("worker %d", i), Workers: 2}
+			go func() {
+				errs <- worker.Work(context.Background(), coordinator.Address())
+			}()
+		}
+	}()
+	remote := run(coordinator)
+	coordinator.Close()
+	if err := <-queued; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(local, remote) {
+		t.Fatal("the results of the workers should be the same as the local results")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result := coordinator.Run(ctx, Job{Dataset: "xor"}); !result.Canceled {
+		t.Fatal("a job without workers should be canceled")
+	}
+}
+
+func TestLease(t *testing.T) {
+	coordinator, err := NewCoordinator("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer coordinator.Close()
+	coordinator.Lease = 100 * time.Millisecond
+	work := &Work{coordinator}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go coordinator.Run(ctx, Job{Dataset: "xor", Seed: 1})
+
+	var job Job
+	err = work.Next("quitter", &job)
+	if err != nil || job.ID != 1 {
+		t.Fatal("the job should be handed out", job.ID, err)
+	}
+	// the job isn't handed out again while its worker is heard from
+	handed := make(chan time.Time)
+	go func() {
+		var backup Job
+		work.Next("backup", &backup)
+		if backup.ID != 1 {
+			t.Error("the job should be handed out again", backup.ID)
+		}
+		handed <- time.Now()
+	}()
+	var last time.Time
+	for i := 0; i < 4; i++ {
+		var ok bool
+		last = time.Now()
+		work.Heartbeat("quitter", &ok)
+		time.Sleep(coordinator.Lease / 2)
+	}
+	if at := <-handed; at.Sub(last) < coordinator.Lease {
+		t.Fatal("the job should be handed out again once the lease of its worker has expired", at.Sub(last))
+	}
+}
+
+// countdown is a context that is canceled after its error has been checked a number of times
+type countdown struct {
+	context.Context
+	checks int
+}
+
+func (c *countdown) Err() error {
+	if c.checks <= 0 {
+		return context.Canceled
+	}
+	c.checks--
+	return nil
+}
+
+func TestCancel(t *testing.T) {
+	directory, err := ioutil.TempDir("", "cancel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	expected := XOR.Run(context.Background(), 1, OptimizerStatic, ModeInception, false, false)
+	experiment := XOR
+	experiment.Checkpoints, experiment.Interval = directory, 1000
+	partial := experiment.Run(&countdown{Context: context.Background(), checks: 7}, 1, OptimizerStatic, ModeInception, false, false)
+	if !partial.Canceled || partial.Converged || len(partial.Costs) != 7 {
+		t.Fatal("run should be abandoned after 7 epochs", partial.Canceled, len(partial.Costs))
+	}
+	actual := experiment.Run(context.Background(), 1, OptimizerStatic, ModeInception, false, false)
+	if actual.Canceled || !Equal(actual.Costs, expected.Costs, 0) {
+		t.Fatal("abandoned run should resume from its checkpoint", len(actual.Costs), len(expected.Costs))
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := XORParallelExperiment(canceled, 1, XOR.Config); err != context.Canceled {
+		t.Fatal("parallel experiment should be canceled", err)
+	}
+	experiment = XOR
+	experiment.Seeds, experiment.Output = 2, &Output{}
+	if err := experiment.RunRepeated(canceled, []Mode{ModeNormal}, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(experiment.Output.Results) != 0 {
+		t.Fatal("canceled experiment shouldn't have results", len(experiment.Output.Results))
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var nothing *Progress
+	nothing.Update("xor", true, 10)
+	nothing.Finish()
+	if NewProgress(nil, "epochs", 2, 1) != nil {
+		t.Fatal("progress without a writer should be nil")
+	}
+
+	buffer := &bytes.Buffer{}
+	progress := NewProgress(buffer, "epochs", 2, 2)
+	if progress.Terminal {
+		t.Fatal("buffer isn't a terminal")
+	}
+	progress.Update("a", true, 10)
+	if buffer.Len() != 0 {
+		t.Fatal("progress should wait for the interval", buffer.String())
+	}
+	progress.Update("a", false, 100)
+	progress.Interval = 0
+	progress.Update("b", true, 30)
+	progress.Finish()
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "a: 2/2 seeds 50.0% converged 10.0 epochs, 2/4 runs") ||
+		!strings.HasPrefix(lines[1], "b: 1/2 seeds 100.0% converged 30.0 epochs, 3/4 runs") {
+		t.Fatal("wrong progress", lines)
+	}
+}
+
+func TestParallelExperiments(t *testing.T) {
+	// the experiments share nothing, so they get the same results when they run at the same time
+	config := XOR.Config
+	config.Depth = 4
+	expected := make([]int, 2)
+	for i := range expected {
+		generations, err := XORParallelExperiment(context.Background(), int64(i+1), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[i] = generations
+	}
+	actual := make([]int, 2)
+	var group sync.WaitGroup
+	for i := range actual {
+		group.Add(1)
+		go func(i int) {
+			defer group.Done()
+			generations, err := XORParallelExperiment(context.Background(), int64(i+1), config)
+			if err != nil {
+				t.Error(err)
+			}
+			actual[i] = generations
+		}(i)
+	}
+	group.Add(1)
+	go func() {
+		defer group.Done()
+		XOR.Run(context.Background(), 1, OptimizerStatic, ModeInception, false, false)
+	}()
+	group.Wait()
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatal("concurrent experiment should get the same generations", actual[i], expected[i])
+		}
+	}
+}
+
+func TestGenetic(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	individuals := make([]ranked, 10)
+	for i := range individuals {
+		individuals[i].Cost = float32(i)
+	}
+	genetic := DefaultGenetic()
+	genetic.Elitism = 4
+	for _, selection := range Selections {
+		genetic.Selection = selection
+		counts := make([]int, len(individuals))
+		for i := 0; i < 10000; i++ {
+			counts[genetic.Select(rnd, individuals)]++
+		}
+		if counts[0] <= counts[9] {
+			t.Fatal("the best should be picked more than the worst", selection, counts)
+		}
+		if selection == SelectionTruncation && counts[4]+counts[5]+counts[6]+counts[7]+counts[8]+counts[9] != 0 {
+			t.Fatal("truncation should only pick the elites", counts)
+		}
+	}
+
+	xor := XOR.Config
+	xor.Depth = 4
+	sum := func(parameters []*tf32.V) (total float32) {
+		for _, p := range parameters {
+			for _, x := range p.X {
+				total += x
+			}
+		}
+		return total
+	}
+	for _, crossover := range Crossovers {
+		genetic.Crossover = crossover
+		a, b := NewXORNetwork(rnd, xor), NewXORNetwork(rnd, xor)
+		before := sum(a.Parameters) + sum(b.Parameters)
+		genetic.Cross(rnd, &a, &b)
+		if after := sum(a.Parameters) + sum(b.Parameters); math.Abs(float64(after-before)) > 1e-3 {
+			t.Fatal("crossover should only move genes between the children", crossover, before, after)
+		}
+	}
+
+	changed := func(a, b []*tf32.V) (count int) {
+		for i := range a {
+			if !Equal(a[i].X, b[i].X, 0) {
+				count++
+			}
+		}
+		return count
+	}
+	for mutation, expected := range map[Mutation]int{MutationGaussian: 4 + 4*4*2, MutationPair: 2, MutationReinit: 2} {
+		genetic.Mutation = mutation
+		a, b := NewXORNetwork(rand.New(rand.NewSource(1)), xor), NewXORNetwork(rand.New(rand.NewSource(1)), xor)
+		genetic.Perturb(rnd, &a, .1)
+		if count := changed(a.Parameters, b.Parameters); count != expected {
+			t.Fatal("wrong number of mutated parameters", mutation, count, expected)
+		}
+	}
+
+	layered := xor
+	layered.Layers, layered.FactorBInit = []int{3, 2}, InitializerZeros
+	network := NewXORNetwork(rand.New(rand.NewSource(1)), layered)
+	if len(network.Genome) != 6 || len(network.Parameters) != 6+6*4*2 {
+		t.Fatal("the network should have the layers of the config", len(network.Genome), len(network.Parameters))
+	}
+	for _, set := range network.Genome {
+		if !Equal(set[1].X, make([]float32, len(set[1].X)), 0) || Equal(set[0].X, make([]float32, len(set[0].X)), 0) {
+			t.Fatal("the factors should have the initializers of the config")
+		}
+	}
+	layered.FactorAInit = InitializerZeros
+	network = NewXORNetwork(rand.New(rand.NewSource(1)), layered)
+	network.Config.FactorAInit, network.Config.FactorBInit = InitializerUniform, InitializerUniform
+	genetic.Mutation = MutationReinit
+	genetic.Perturb(rnd, &network, .1)
+	reinitialized := 0
+	for _, p := range network.Parameters {
+		if !Equal(p.X, make([]float32, len(p.X)), 0) {
+			reinitialized++
+		}
+	}
+	if reinitialized != 2+6 {
+		t.Fatal("reinit should draw a factor pair from the initializers", reinitialized)
+	}
+
+	config := XOR.Config
+	config.Depth, config.Genetic.Generations = 4, 3
+	for _, mutation := range Mutations {
+		config.Genetic.Mutation = mutation
+		if _, err := XORParallelExperiment(context.Background(), 1, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config.Genetic.Mutation = MutationGradient
+	generations, err := XORParallelExperiment(context.Background(), 1, config)
+	if err != nil || generations != 3 {
+		t.Fatal("evolution should stop after 3 generations", generations, err)
+	}
+	converged := config
+	converged.BatchThreshold = 100
+	if generations, err := XORParallelExperiment(context.Background(), 1, converged); err != nil || generations != 0 {
+		t.Fatal("evolution should stop at the batch threshold of the config", generations, err)
+	}
+	frozen := xor
+	frozen.Eta = 0
+	a, b := NewXORNetwork(rand.New(rand.NewSource(1)), frozen), NewXORNetwork(rand.New(rand.NewSource(1)), frozen)
+	a.Mutate()
+	if changed(a.Parameters, b.Parameters) != 0 {
+		t.Fatal("mutation should use the learning rate of the config")
+	}
+	config.Genetic.Population, config.Genetic.Elitism = 11, 4
+	config.Genetic.Selection, config.Genetic.Crossover = SelectionTournament, CrossoverUniform
+	if _, err := XORParallelExperiment(context.Background(), 1, config); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, topology := range Topologies {
+		config.Genetic.Islands, config.Genetic.Topology, config.Genetic.Interval = 3, topology, 1
+		if _, err := XORParallelExperiment(context.Background(), 1, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	genetic.Elitism, genetic.Migrants = 1, 1
+	for topology, expected := range map[Topology][]int{TopologyRing: {2, 0, 1}, TopologyFull: {1, 0, 0}} {
+		genetic.Topology = topology
+		islands := make([]*Island, 3)
+		for i := range islands {
+			population := make([]Individual, 3)
+			for j := range population {
+				network := NewXORNetwork(rand.New(rand.NewSource(int64(3*i+j))), xor)
+				population[j] = &network
+			}
+			islands[i] = genetic.NewIsland(rnd, population)
+		}
+		genes := func(i, j int) []*tf32.V {
+			parameters, _ := islands[i].Individuals[j].Genes()
+			return parameters
+		}
+		best := [][]*tf32.V{genes(0, 0), genes(1, 0), genes(2, 0)}
+		clones := make([][]*tf32.V, 3)
+		for i := range clones {
+			network := NewXORNetwork(rand.New(rand.NewSource(int64(3*i))), xor)
+			clones[i] = network.Parameters
+		}
+		genetic.Migrate(islands)
+		for i := range islands {
+			if changed(genes(i, 0), clones[i]) != 0 {
+				t.Fatal("migrants shouldn't replace the elites", topology, i)
+			}
+			if changed(genes(i, 2), best[expected[i]]) != 0 {
+				t.Fatal("the last individual should be a migrant", topology, i, expected[i])
+			}
+		}
+	}
+	empty := XOR.Config
+	empty.Depth = 0
+	if err := empty.Validate(); err == nil {
+		t.Fatal("a genetic algorithm without a depth should be invalid")
+	}
+	empty.Depth, empty.Factorized = 4, []int{2}
+	if err := empty.Validate(); err == nil {
+		t.Fatal("a genetic algorithm without factorized layers should be invalid")
+	}
+	empty.Genetic.Crossover, empty.Genetic.Mutation = CrossoverBlend, MutationGaussian
+	if err := empty.Validate(); err != nil {
+		t.Fatal("a genetic algorithm that doesn't pick factor pairs doesn't need a genome", err)
+	}
+
+	for _, change := range []func(g *Genetic){
+		func(g *Genetic) { g.Population = 1 },
+		func(g *Genetic) { g.Elitism = g.Population },
+		func(g *Genetic) { g.Elitism = -1 },
+		func(g *Genetic) { g.Islands = 0 },
+		func(g *Genetic) { g.Islands = -2 },
+		func(g *Genetic) { g.Migrants = g.Elitism + 1 },
+		func(g *Genetic) { g.Migrants = -1 },
+	} {
+		invalid := XOR.Config
+		change(&invalid.Genetic)
+		if err := invalid.Validate(); err == nil {
+			t.Fatal("the genetic algorithm should be invalid", invalid.Genetic)
+		}
+	}
+	if csv := CSV.Config; csv.Validate() != nil {
+		t.Fatal("a config without a genetic algorithm shouldn't be checked")
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/pointlander/gradient/tf32"
+)
+
+// Initializer is a method for initializing parameters
+type Initializer int
+
+const (
+	// InitializerUniform draws from a uniform distribution between -scale and scale
+	InitializerUniform Initializer = iota
+	// InitializerXavier draws from a uniform distribution with a variance of 2/(fan in + fan out)
+	InitializerXavier
+	// InitializerHe draws from a normal distribution with a variance of 2/fan in
+	InitializerHe
+	// InitializerLeCun draws from a normal distribution with a variance of 1/fan in
+	InitializerLeCun
+	// InitializerOrthogonal is a random orthogonal matrix multiplied by scale
+	InitializerOrthogonal
+	// InitializerNormal draws from a normal distribution with a standard deviation of scale
+	InitializerNormal
+	// InitializerZeros sets the parameters to zero
+	InitializerZeros
+)
+
+// Initializers the initializers
+var Initializers = [...]Initializer{
+	InitializerUniform,
+	InitializerXavier,
+	InitializerHe,
+	InitializerLeCun,
+	InitializerOrthogonal,
+	InitializerNormal,
+	InitializerZeros,
+}
+
+// Converts the initializer to a string
+func (i Initializer) String() string {
+	switch i {
+	case InitializerUniform:
+		return "uniform"
+	case InitializerXavier:
+		return "xavier"
+	case InitializerHe:
+		return "he"
+	case InitializerLeCun:
+		return "lecun"
+	case InitializerOrthogonal:
+		return "orthogonal"
+	case InitializerNormal:
+		return "normal"
+	case InitializerZeros:
+		return "zeros"
+	}
+	return "unknown"
+}
+
+// Set sets the initializer from its name
+func (i *Initializer) Set(name string) error {
+	for _, initializer := range Initializers {
+		if initializer.String() == name {
+			*i = initializer
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown initializer %s", name)
+}
+
+// MarshalText converts the initializer to its name
+func (i Initializer) MarshalText() ([]byte, error) {
+	return []byte(i.String()), nil
+}
+
+// UnmarshalText sets the initializer from its name
+func (i *Initializer) UnmarshalText(text []byte) error {
+	return i.Set(string(text))
+}
+
+// Initialize fills the values of a parameter
+// The fan in is the first dimension of the parameter and the fan out is the second
+func (i Initializer) Initialize(rnd *rand.Rand, p *tf32.V, scale float32) {
+	fanIn, fanOut := float64(p.S[0]), float64(p.S[1])
+	normal := func(deviation float64) {
+		for j := 0; j < cap(p.X); j++ {
+			p.X = append(p.X, float32(rnd.NormFloat64()*deviation))
+		}
+	}
+	switch i {
+	case InitializerUniform:
+		for j := 0; j < cap(p.X); j++ {
+			p.X = append(p.X, 2*scale*rnd.Float32()-scale)
+		}
+	case InitializerXavier:
+		limit := float32(math.Sqrt(6 / (fanIn + fanOut)))
+		for j := 0; j < cap(p.X); j++ {
+			p.X = append(p.X, 2*limit*rnd.Float32()-limit)
+		}
+	case InitializerHe:
+		normal(math.Sqrt(2 / fanIn))
+	case InitializerLeCun:
+		normal(math.Sqrt(1 / fanIn))
+	case InitializerOrthogonal:
+		p.X = append(p.X, Orthogonal(rnd, p.S[0], p.S[1], scale)...)
+	case InitializerNormal:
+		normal(float64(scale))
+	case InitializerZeros:
+		for j := 0; j < cap(p.X); j++ {
+			p.X = append(p.X, 0)
+		}
+	default:
+		panic(fmt.Sprintf("unknown initializer %d", i))
+	}
+}
+
+// Orthogonal creates a random matrix with rows of the given width and orthonormal rows or columns
+// The gram schmidt process is applied to the smaller of the rows and the columns
+func Orthogonal(rnd *rand.Rand, width, height int, scale float32) []float32 {
+	vectors, size := height, width
+	if height > width {
+		vectors, size = width, height
+	}
+	q := make([][]float64, vectors)
+	for i := range q {
+		for {
+			v := make([]float64, size)
+			for j := range v {
+				v[j] = rnd.NormFloat64()
+			}
+			for _, u := range q[:i] {
+				dot := 0.0
+				for j := range v {
+					dot += v[j] * u[j]
+				}
+				for j := range v {
+					v[j] -= dot * u[j]
+				}
+			}
+			norm := 0.0
+			for _, value := range v {
+				norm += value * value
+			}
+			norm = math.Sqrt(norm)
+			// a vector that is nearly dependent on the others is drawn again
+			if norm > 1e-6 {
+				for j := range v {
+					v[j] /= norm
+				}
+				q[i] = v
+				break
+			}
+		}
+	}
+	matrix := make([]float32, width*height)
+	for i, v := range q {
+		for j, value := range v {
+			if height > width {
+				matrix[j*width+i] = scale * float32(value)
+			} else {
+				matrix[i*width+j] = scale * float32(value)
+			}
+		}
+	}
+	return matrix
+}
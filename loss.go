@@ -0,0 +1,317 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pointlander/gradient/tf32"
+)
+
+// Loss is the cost function of a network
+type Loss int
+
+const (
+	// LossDefault is the loss of the dataset
+	LossDefault Loss = iota
+	// LossQuadratic is half of the sum of the squared errors
+	LossQuadratic
+	// LossMSE is the mean of the squared errors
+	LossMSE
+	// LossMAE is the mean of the absolute errors
+	LossMAE
+	// LossHuber is the mean of the huber loss with a delta of 1
+	LossHuber
+	// LossBinaryCrossEntropy is the cross entropy of each output as an independent probability
+	LossBinaryCrossEntropy
+	// LossCategoricalCrossEntropy is the cross entropy of the outputs as one distribution
+	// It should be used with the softmax activation and not the softmax of tf32
+	LossCategoricalCrossEntropy
+	// LossHinge is the mean of the hinge loss with 0 and 1 targets mapped to -1 and 1
+	// It needs an output activation that can reach -1 and 1 such as tanh or identity
+	LossHinge
+	// LossFocal is the binary cross entropy weighted by the squared error
+	LossFocal
+)
+
+// Losses the losses
+var Losses = [...]Loss{
+	LossDefault,
+	LossQuadratic,
+	LossMSE,
+	LossMAE,
+	LossHuber,
+	LossBinaryCrossEntropy,
+	LossCategoricalCrossEntropy,
+	LossHinge,
+	LossFocal,
+}
+
+// Converts the loss to a string
+func (l Loss) String() string {
+	switch l {
+	case LossDefault:
+		return "default"
+	case LossQuadratic:
+		return "quadratic"
+	case LossMSE:
+		return "mse"
+	case LossMAE:
+		return "mae"
+	case LossHuber:
+		return "huber"
+	case LossBinaryCrossEntropy:
+		return "bce"
+	case LossCategoricalCrossEntropy:
+		return "cce"
+	case LossHinge:
+		return "hinge"
+	case LossFocal:
+		return "focal"
+	}
+	return "unknown"
+}
+
+// Set sets the loss from its name
+func (l *Loss) Set(name string) error {
+	for _, loss := range Losses {
+		if loss.String() == name {
+			*l = loss
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown loss %s", name)
+}
+
+// MarshalText converts the loss to its name
+func (l Loss) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText sets the loss from its name
+func (l *Loss) UnmarshalText(text []byte) error {
+	return l.Set(string(text))
+}
+
+// Cost is the average loss of a batch of outputs with the training operators
+func (l Loss) Cost(output, expected tf32.Meta) tf32.Meta {
+	return l.CostWith(Training, output, expected)
+}
+
+// CostWith is the average loss of a batch of outputs with the given operators
+func (l Loss) CostWith(o *Operators, output, expected tf32.Meta) tf32.Meta {
+	switch l {
+	case LossQuadratic:
+		return o.Avg(o.Quadratic(output, expected))
+	case LossMSE:
+		return o.Avg(o.MSE(output, expected))
+	case LossMAE:
+		return o.Avg(o.MAE(output, expected))
+	case LossHuber:
+		return o.Avg(o.Huber(output, expected))
+	case LossBinaryCrossEntropy:
+		return o.Avg(o.CrossEntropy(output, expected))
+	case LossCategoricalCrossEntropy:
+		return o.Avg(o.CategoricalCrossEntropy(output, expected))
+	case LossHinge:
+		return o.Avg(o.Hinge(output, expected))
+	case LossFocal:
+		return o.Avg(o.Focal(output, expected))
+	}
+	panic(fmt.Sprintf("loss %s has no cost", l))
+}
+
+// Threshold is the mean cost per batch below which training has converged
+// The thresholds are about the cost of outputs that are all within .1 of the targets
+func (l Loss) Threshold() float32 {
+	switch l {
+	case LossQuadratic:
+		// .5*.1^2
+		return .005
+	case LossMSE:
+		// .1^2
+		return .01
+	case LossMAE:
+		return .1
+	case LossHuber:
+		// .5*.1^2
+		return .005
+	case LossBinaryCrossEntropy:
+		// -log(.9) for each output of a pair of outputs
+		return .2
+	case LossCategoricalCrossEntropy:
+		// -log(.9)
+		return .1
+	case LossHinge:
+		// 1 - .9
+		return .1
+	case LossFocal:
+		// .1^2 * -log(.9) for each output of a pair of outputs
+		return .002
+	}
+	return 0
+}
+
+// Criterion is the method for deciding if training has converged
+type Criterion int
+
+const (
+	// CriterionTotal compares the total cost of an epoch to the thresholds of the configuration
+	CriterionTotal Criterion = iota
+	// CriterionMean compares the mean cost per batch of an epoch to the threshold of the loss
+	// It doesn't depend on the size of the dataset or the batch size
+	CriterionMean
+)
+
+// Criteria the criteria
+var Criteria = [...]Criterion{
+	CriterionTotal,
+	CriterionMean,
+}
+
+// Converts the criterion to a string
+func (c Criterion) String() string {
+	switch c {
+	case CriterionTotal:
+		return "total"
+	case CriterionMean:
+		return "mean"
+	}
+	return "unknown"
+}
+
+// Set sets the criterion from its name
+func (c *Criterion) Set(name string) error {
+	for _, criterion := range Criteria {
+		if criterion.String() == name {
+			*c = criterion
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown criterion %s", name)
+}
+
+// MarshalText converts the criterion to its name
+func (c Criterion) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText sets the criterion from its name
+func (c *Criterion) UnmarshalText(text []byte) error {
+	return c.Set(string(text))
+}
+
+// column creates a cost operator of a context that sums or averages an element wise loss over each column
+// The derivative of the loss is with respect to the output a
+func column(context *tf32.Context, f, df func(a, b float32) float32, mean bool) func(a, b tf32.Meta) tf32.Meta {
+	return tf32.B(func(a, b *tf32.V) func(k tf32.Continuation) {
+		return func(k tf32.Continuation) {
+			if len(a.S) != 2 || len(b.S) != 2 {
+				panic("tensor needs to have two dimensions")
+			}
+			width := a.S[0]
+			if width != b.S[0] || a.S[1] != b.S[1] {
+				panic("dimensions are not the same")
+			}
+			scale := float32(1)
+			if mean {
+				scale = 1 / float32(width)
+			}
+			c, size := tf32.NewV(a.S[1]), len(a.X)
+			for i := 0; i < size; i += width {
+				av, bv, sum := a.X[i:i+width], b.X[i:i+width], float32(0.0)
+				for j, ax := range av {
+					sum += f(ax, bv[j])
+				}
+				c.X = append(c.X, scale*sum)
+			}
+			k(&c)
+			if context.InferenceOnly {
+				return
+			}
+			index := 0
+			for i := 0; i < size; i += width {
+				av, bv, ad, d := a.X[i:i+width], b.X[i:i+width], a.D[i:i+width], c.D[index]
+				for j, ax := range av {
+					ad[j] += scale * df(ax, bv[j]) * d
+				}
+				index++
+			}
+		}
+	})
+}
+
+func log(x float32) float32 {
+	return float32(math.Log(float64(x)))
+}
+
+func sign(x float32) float32 {
+	if x < 0 {
+		return -1
+	} else if x > 0 {
+		return 1
+	}
+	return 0
+}
+
+// losses creates the loss operators
+func (o *Operators) losses() {
+	o.MSE = column(o.Context, func(a, b float32) float32 {
+		return (a - b) * (a - b)
+	}, func(a, b float32) float32 {
+		return 2 * (a - b)
+	}, true)
+	o.MAE = column(o.Context, func(a, b float32) float32 {
+		if a < b {
+			return b - a
+		}
+		return a - b
+	}, func(a, b float32) float32 {
+		return sign(a - b)
+	}, true)
+	o.Huber = column(o.Context, func(a, b float32) float32 {
+		x := a - b
+		if x > 1 || x < -1 {
+			return sign(x)*x - .5
+		}
+		return .5 * x * x
+	}, func(a, b float32) float32 {
+		x := a - b
+		if x > 1 || x < -1 {
+			return sign(x)
+		}
+		return x
+	}, true)
+	o.CategoricalCrossEntropy = column(o.Context, func(a, b float32) float32 {
+		return -b * log(a+.001)
+	}, func(a, b float32) float32 {
+		return -b / (a + .001)
+	}, false)
+	o.Hinge = column(o.Context, func(a, b float32) float32 {
+		y := 2*b - 1
+		if margin := 1 - y*a; margin > 0 {
+			return margin
+		}
+		return 0
+	}, func(a, b float32) float32 {
+		y := 2*b - 1
+		if 1-y*a > 0 {
+			return -y
+		}
+		return 0
+	}, true)
+	o.Focal = column(o.Context, func(a, b float32) float32 {
+		if b == 1 {
+			return -(1 - a) * (1 - a) * log(a+.001)
+		}
+		return -a * a * log(1-a+.001)
+	}, func(a, b float32) float32 {
+		if b == 1 {
+			return 2*(1-a)*log(a+.001) - (1-a)*(1-a)/(a+.001)
+		}
+		return -2*a*log(1-a+.001) + a*a/(1-a+.001)
+	}, false)
+}
@@ -5,37 +5,95 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"image/color"
 	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/pointlander/gradient/tf32"
 )
 
 // Result an experiment result
 type Result struct {
-	Costs     []float32
-	Converged bool
-	Misses    int
+	Dataset   string
+	Seed      int64
+	Mode      Mode
+	Optimizer OptimizerType
+	// Batch is the batch size
+	Batch           int
+	Context         bool
+	Costs           []float32
+	ValidationCosts []float32
+	Converged       bool
+	// Canceled is set if the run was abandoned before it finished
+	Canceled bool
+	Misses   int
+	// Confusion is the confusion matrix of the test set
+	Confusion Confusion
+	Config    Config
+	// Error is why the run failed, a run that failed hasn't converged
+	Error string
 }
 
 // Statistics aggregation of results
 type Statistics struct {
-	Mode      string
-	Optimizer Optimizer
+	Mode      Mode
+	Optimizer OptimizerType
 	Batch     int
+	Context   bool
+	Config    Config
 	Count     int
 	Converged int
 	Epochs    int
+	Tested    int
+	Accuracy  float64
+	// Confusion is the sum of the confusion matrices of the test sets
+	Confusion Confusion
+	// Samples are the epochs of the seeds that converged
+	Samples []float64
+	// Runs are the epochs of every seed by seed, which is the maximum epochs if it didn't converge
+	Runs map[int64]float64
+	// Costs are the costs of every seed if the costs are plotted
+	Costs [][]float32
+}
+
+// Name is the mode, optimizer, batch and context of the statistics
+func (s *Statistics) Name() string {
+	name := fmt.Sprintf("%s %s batch %d", s.Mode, s.Optimizer, s.Batch)
+	if s.Context {
+		name += " context"
+	}
+	return name
 }
 
 // Aggregate adds the results to the statistics
 func (s *Statistics) Aggregate(result Result) {
 	s.Count++
+	if s.Runs == nil {
+		s.Runs = make(map[int64]float64)
+	}
+	s.Runs[result.Seed] = float64(len(result.Costs))
 	if result.Converged {
 		s.Converged++
 		s.Epochs += len(result.Costs)
+		s.Samples = append(s.Samples, float64(len(result.Costs)))
+	}
+	if result.Confusion != nil {
+		s.Tested++
+		s.Accuracy += result.Confusion.Accuracy()
+		if s.Confusion == nil {
+			s.Confusion = NewConfusion(len(result.Confusion))
+		}
+		s.Confusion.Add(result.Confusion)
 	}
 }
 
@@ -44,48 +102,179 @@ func (s *Statistics) ConvergenceProbability() float64 {
 	return float64(s.Converged) / float64(s.Count)
 }
 
-// AverageEpochs the average epochs
+// AverageEpochs the average epochs of the seeds that converged, 0 if none converged
 func (s *Statistics) AverageEpochs() float64 {
+	if s.Converged == 0 {
+		return 0
+	}
 	return float64(s.Epochs) / float64(s.Converged)
 }
 
+// MedianEpochs the median epochs of the seeds that converged
+func (s *Statistics) MedianEpochs() float64 {
+	return Median(s.Samples)
+}
+
+// StdDevEpochs the standard deviation of the epochs of the seeds that converged
+func (s *Statistics) StdDevEpochs() float64 {
+	return StdDev(s.Samples)
+}
+
+// PercentileEpochs the pth percentile of the epochs of the seeds that converged
+func (s *Statistics) PercentileEpochs(p float64) float64 {
+	return Percentile(s.Samples, p)
+}
+
+// ConfidenceInterval the 95% bootstrap confidence interval of the average epochs
+func (s *Statistics) ConfidenceInterval() (lower, upper float64) {
+	return Bootstrap(rand.New(rand.NewSource(1)), s.Samples, 1000, .95)
+}
+
+// Comparison is a paired comparison of the epochs of two modes for the same seeds
+type Comparison struct {
+	Baseline, Other *Statistics
+	// Speedup is the average epochs of the baseline divided by the average epochs of the other
+	Speedup float64
+	// W, P and N are the statistic, the p value and the number of pairs of the wilcoxon signed rank test
+	W, P float64
+	N    int
+}
+
+// Compare compares the epochs of the other statistics to the baseline for the seeds they share
+// The seeds that didn't converge count as the maximum epochs
+func Compare(baseline, other *Statistics) Comparison {
+	seeds := []int64{}
+	for seed := range baseline.Runs {
+		if _, ok := other.Runs[seed]; ok {
+			seeds = append(seeds, seed)
+		}
+	}
+	sort.Slice(seeds, func(i, j int) bool {
+		return seeds[i] < seeds[j]
+	})
+	a, b := make([]float64, len(seeds)), make([]float64, len(seeds))
+	for i, seed := range seeds {
+		a[i], b[i] = baseline.Runs[seed], other.Runs[seed]
+	}
+	comparison := Comparison{
+		Baseline: baseline,
+		Other:    other,
+	}
+	if epochs := other.AverageEpochs(); epochs > 0 {
+		comparison.Speedup = baseline.AverageEpochs() / epochs
+	}
+	comparison.W, comparison.P, comparison.N = Wilcoxon(a, b)
+	return comparison
+}
+
+// AverageAccuracy the average test accuracy
+func (s *Statistics) AverageAccuracy() float64 {
+	return s.Accuracy / float64(s.Tested)
+}
+
+// Precision the precision of each class over the test sets of every seed
+func (s *Statistics) Precision() []float64 {
+	precision := make([]float64, len(s.Confusion))
+	for i := range precision {
+		precision[i] = s.Confusion.Precision(i)
+	}
+	return precision
+}
+
+// Recall the recall of each class over the test sets of every seed
+func (s *Statistics) Recall() []float64 {
+	recall := make([]float64, len(s.Confusion))
+	for i := range recall {
+		recall[i] = s.Confusion.Recall(i)
+	}
+	return recall
+}
+
 // String generates a string for the statistics
 func (s *Statistics) String() string {
 	return fmt.Sprintf("%f %f", s.ConvergenceProbability(), s.AverageEpochs())
 }
 
-// Optimizer an optimizer type
-type Optimizer int
+// Mode is the form of the network weights
+type Mode int
 
 const (
-	// OptimizerStatic is a static learning optimizer
-	OptimizerStatic Optimizer = iota
-	// OptimizerMomentum basic optimizer
-	OptimizerMomentum
-	// OptimizerAdam the adam optimizer
-	OptimizerAdam
+	// ModeNormal is a normal network with sigmoid(A*X + B) layers
+	ModeNormal Mode = iota
+	// ModeInception is a network with sigmoid((A1*A2 + A3)*X + B1*B2 + B3) layers
+	ModeInception
+	// ModeDCT is a network with weights in the dct domain
+	ModeDCT
 )
 
-// Optimizers the optimizers
-var Optimizers = [...]Optimizer{
-	OptimizerStatic,
-	OptimizerMomentum,
-	OptimizerAdam,
+// Modes the modes
+var Modes = [...]Mode{
+	ModeNormal,
+	ModeInception,
+	ModeDCT,
 }
 
-// Converts the optimzer to a string
-func (o Optimizer) String() string {
-	switch o {
-	case OptimizerStatic:
-		return "static"
-	case OptimizerMomentum:
-		return "momentum"
-	case OptimizerAdam:
-		return "adam"
+// Converts the mode to a string
+func (m Mode) String() string {
+	switch m {
+	case ModeNormal:
+		return "normal"
+	case ModeInception:
+		return "inception"
+	case ModeDCT:
+		return "dct"
 	}
 	return "unknown"
 }
 
+// Set sets the mode from its name
+func (m *Mode) Set(name string) error {
+	for _, mode := range Modes {
+		if mode.String() == name {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown mode %s", name)
+}
+
+// MarshalText converts the mode to its name
+func (m Mode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText sets the mode from its name
+func (m *Mode) UnmarshalText(text []byte) error {
+	return m.Set(string(text))
+}
+
+// ParseModes parses a comma separated list of modes
+func ParseModes(list string) ([]Mode, error) {
+	modes := []Mode{}
+	for _, name := range strings.Split(list, ",") {
+		var mode Mode
+		err := mode.Set(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		modes = append(modes, mode)
+	}
+	return modes, nil
+}
+
+// ParseInput parses a comma separated list of numbers
+func ParseInput(list string) ([]float32, error) {
+	input := []float32{}
+	for _, value := range strings.Split(list, ",") {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 32)
+		if err != nil {
+			return nil, err
+		}
+		input = append(input, float32(parsed))
+	}
+	return input, nil
+}
+
 var colors = [...]color.RGBA{
 	{R: 0x00, G: 0x3f, B: 0x5c, A: 255},
 	{R: 0x44, G: 0x4e, B: 0x86, A: 255},
@@ -142,32 +331,272 @@ var (
 	irisExperiment = flag.Bool("iris", false, "run the iris experiment")
 	parallel       = flag.Bool("parallel", false, "run the experiment parallelly")
 	repeated       = flag.Bool("repeated", false, "run the experiment repeatedly")
+	mode           = flag.String("mode", "normal,inception", "comma separated list of modes to compare: normal, inception, dct")
+	contextual     = flag.Bool("context", false, "also run with a per datum optimizer context")
+	configFile     = flag.String("config", "", "json file with the experiment configuration")
+	checkpoints    = flag.String("checkpoints", "", "directory for resumable checkpoints of the training runs")
+	interval       = flag.Int("interval", 100, "the number of epochs between checkpoints")
+	loadFile       = flag.String("load", "", "load a trained network from a checkpoint for inference")
+	input          = flag.String("input", "", "comma separated input for the loaded network")
+	csvFile        = flag.String("csv", "", "run the experiment on a csv or tsv file")
+	header         = flag.Bool("header", false, "the first row of the csv file names the columns")
+	features       = flag.String("features", "", "comma separated names or indexes of the csv feature columns, the other columns by default")
+	labels         = flag.String("labels", "", "comma separated names or indexes of the csv label columns")
+	timeout        = flag.Duration("timeout", 0, "stop the experiment and report the finished runs after a duration, no limit if it is 0")
+	workers        = flag.Int("workers", runtime.GOMAXPROCS(0), "the number of concurrent runs of the repeated experiments")
+	plotCosts      = flag.Bool("plot", false, "plot the median costs and the epochs to convergence of the repeated experiments")
+	logScale       = flag.Bool("log", false, "plot the costs on a log scale")
+	serveWork      = flag.String("serve-work", "", "hand out the runs of the repeated experiments to workers that connect to an address such as :7777")
+	worker         = flag.String("worker", "", "run the jobs of the coordinator at an address such as localhost:7777")
+	modelFile      = flag.String("model", "", "the json model file to serve, or to write from the checkpoint of -load")
+	address        = flag.String("address", ":8080", "the address of the inference server")
+	target         = TargetOneHot
+	normalization  = NormalizationZScore
+	outputFile     OutputFile
+	flags          Config
 )
 
+// loadCSV loads the csv dataset and makes it available to checkpoints
+func loadCSV() {
+	options := CSVOptions{
+		Header:        *header,
+		Labels:        strings.Split(*labels, ","),
+		Target:        target,
+		Normalization: normalization,
+	}
+	if *labels == "" {
+		options.Labels = nil
+	}
+	if *features != "" {
+		options.Features = strings.Split(*features, ",")
+	}
+	dataset, err := LoadCSV(*csvFile, options)
+	if err != nil {
+		panic(err)
+	}
+	err = Register(dataset)
+	if err != nil {
+		panic(err)
+	}
+	CSV.Dataset = dataset
+}
+
+// configure overrides the default configuration with the configuration file and flags
+func configure(config Config) Config {
+	if *configFile != "" {
+		err := config.Load(*configFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+	config.Override(&flags)
+	err := config.Validate()
+	if err != nil {
+		panic(err)
+	}
+	return config
+}
+
 func main() {
+	flag.Var(&target, "target", "the encoding of the csv labels: onehot or numeric")
+	flag.Var(&normalization, "normalize", "the normalization of the csv features: none, max, minmax or zscore")
+	flag.Var(&outputFile, "output", "write the results to a json, jsonl or csv file")
+	flags.Flags()
 	flag.Parse()
+	// the only command is serve and the flags can follow it
+	command := flag.Arg(0)
+	if command != "" {
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	if command != "" && command != "serve" {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	modes, err := ParseModes(*mode)
+	if err != nil {
+		panic(err)
+	}
+
+	if *csvFile != "" {
+		loadCSV()
+	}
+
+	if command == "serve" {
+		if *modelFile == "" {
+			fmt.Fprintln(os.Stderr, "serve needs a -model file")
+			os.Exit(2)
+		}
+		model, err := LoadModel(*modelFile)
+		if err != nil {
+			panic(err)
+		}
+		server, err := NewServer(model)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintf(os.Stderr, "serving the %s %s model on %s\n", model.Dataset, model.Mode, *address)
+		err = http.ListenAndServe(*address, server)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	if *loadFile != "" {
+		checkpoint, err := LoadCheckpoint(*loadFile)
+		if err != nil {
+			panic(err)
+		}
+		if *modelFile != "" {
+			model, err := checkpoint.Model()
+			if err != nil {
+				panic(err)
+			}
+			err = model.Save(*modelFile)
+			if err != nil {
+				panic(err)
+			}
+			return
+		}
+		network, err := checkpoint.Network()
+		if err != nil {
+			panic(err)
+		}
+		in, err := ParseInput(*input)
+		if err != nil {
+			panic(err)
+		}
+		if len(in) != network.In {
+			panic(fmt.Sprintf("input should have %d values", network.In))
+		}
+		fmt.Println(network.Infer(in))
+		return
+	}
+
+	if *checkpoints != "" {
+		err := os.MkdirAll(*checkpoints, 0755)
+		if err != nil {
+			panic(err)
+		}
+	}
+	XOR.Checkpoints, XOR.Interval, XOR.Workers = *checkpoints, *interval, *workers
+	Iris.Checkpoints, Iris.Interval, Iris.Workers = *checkpoints, *interval, *workers
+	CSV.Checkpoints, CSV.Interval, CSV.Workers = *checkpoints, *interval, *workers
+
+	var output *Output
+	if outputFile != "" {
+		output = &Output{}
+	}
+	XOR.Output, Iris.Output, CSV.Output = output, output, output
+	XOR.Progress, Iris.Progress, CSV.Progress = os.Stderr, os.Stderr, os.Stderr
+	XOR.Plot, Iris.Plot, CSV.Plot = *plotCosts, *plotCosts, *plotCosts
+	XOR.LogScale, Iris.LogScale, CSV.LogScale = *logScale, *logScale, *logScale
+
+	// the first interrupt stops the experiment and the second one exits
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if *timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(interrupts)
+	}()
+
+	if *worker != "" {
+		hostname, _ := os.Hostname()
+		w := Worker{
+			Name:        fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+			Checkpoints: *checkpoints,
+			Interval:    *interval,
+			Workers:     *workers,
+		}
+		err := w.Work(ctx, *worker)
+		if err != nil && ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, "interrupted")
+		} else if err != nil {
+			panic(err)
+		}
+		return
+	}
+	if *serveWork != "" {
+		coordinator, err := NewCoordinator(*serveWork)
+		if err != nil {
+			panic(err)
+		}
+		defer coordinator.Close()
+		fmt.Fprintf(os.Stderr, "waiting for workers on %s\n", coordinator.Address())
+		XOR.Coordinator, Iris.Coordinator, CSV.Coordinator = coordinator, coordinator, coordinator
+	}
+	save := func() {
+		err := output.Save(string(outputFile))
+		if err != nil {
+			panic(err)
+		}
+	}
+	// the output of a failed experiment is saved before it exits with the error
+	check := func(err error) {
+		if err != nil {
+			save()
+			panic(err)
+		}
+	}
 
 	if *xorExperiment {
+		XOR.Config = configure(XOR.Config)
 		if *repeated && *parallel {
-			RunXORRepeatedParallelExperiment()
+			RunXORRepeatedParallelExperiment(ctx, XOR.Config, output, os.Stderr)
 		} else if *repeated {
-			RunXORRepeatedExperiment()
+			check(XOR.RunRepeated(ctx, modes, *contextual))
 		} else if *parallel {
-			XORParallelExperiment(*seed, 16)
+			generations, err := XORParallelExperiment(ctx, *seed, XOR.Config)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "interrupted after %d generations\n", generations)
+			} else {
+				output.AddGenerations("xor", *seed, generations)
+				fmt.Printf("generations=%d\n", generations)
+			}
 		} else {
-			RunXORExperiment(*seed)
+			check(XOR.RunOnce(ctx, *seed, modes, *contextual))
 		}
+		save()
 		return
 	} else if *irisExperiment {
+		Iris.Config = configure(Iris.Config)
 		if *repeated && *parallel {
-			RunIrisRepeatedParallelExperiment()
+			RunIrisRepeatedParallelExperiment(ctx, Iris.Config, output, os.Stderr)
 		} else if *repeated {
-			RunIrisRepeatedExperiment()
+			check(Iris.RunRepeated(ctx, modes, *contextual))
 		} else if *parallel {
-			IrisParallelExperiment(*seed, 4)
+			generations, err := IrisParallelExperiment(ctx, *seed, Iris.Config)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "interrupted after %d generations\n", generations)
+			} else {
+				output.AddGenerations("iris", *seed, generations)
+				fmt.Printf("generations=%d\n", generations)
+			}
+		} else {
+			check(Iris.RunOnce(ctx, *seed, modes, *contextual))
+		}
+		save()
+		return
+	} else if *csvFile != "" {
+		CSV.Config = configure(CSV.Config)
+		if *repeated {
+			check(CSV.RunRepeated(ctx, modes, *contextual))
 		} else {
-			RunIrisExperiment(*seed)
+			check(CSV.RunOnce(ctx, *seed, modes, *contextual))
 		}
+		save()
 		return
 	}
 
@@ -0,0 +1,284 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+
+	"github.com/pointlander/gradient/tf32"
+)
+
+// ModelVersion is the version of the model format
+const ModelVersion = 2
+
+// Task is the kind of problem a model solves
+type Task int
+
+const (
+	// TaskClassification predicts the probabilities of one hot classes
+	TaskClassification Task = iota
+	// TaskRegression predicts the values of the outputs
+	TaskRegression
+)
+
+// Tasks the tasks
+var Tasks = [...]Task{
+	TaskClassification,
+	TaskRegression,
+}
+
+// Converts the task to a string
+func (t Task) String() string {
+	switch t {
+	case TaskClassification:
+		return "classification"
+	case TaskRegression:
+		return "regression"
+	}
+	return "unknown"
+}
+
+// Set sets the task from its name
+func (t *Task) Set(name string) error {
+	for _, task := range Tasks {
+		if task.String() == name {
+			*t = task
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown task %s", name)
+}
+
+// MarshalText converts the task to its name
+func (t Task) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText sets the task from its name
+func (t *Task) UnmarshalText(text []byte) error {
+	return t.Set(string(text))
+}
+
+// Tensor is a matrix of a model
+type Tensor struct {
+	Shape  []int     `json:"shape"`
+	Values []float32 `json:"values"`
+}
+
+// Model is a trained network for inference stored as json
+// The weight expressions are compressed to plain matrices, so normal, inception and dct networks have the same form
+// A model has everything needed to rebuild its network, so it doesn't depend on the dataset it was trained on
+type Model struct {
+	Version   int           `json:"version"`
+	Dataset   string        `json:"dataset"`
+	Mode      Mode          `json:"mode"`
+	Optimizer OptimizerType `json:"optimizer"`
+	Seed      int64         `json:"seed"`
+	Epochs    int           `json:"epochs"`
+	Converged bool          `json:"converged"`
+	Config    Config        `json:"config"`
+	In        int           `json:"in"`
+	Out       int           `json:"out"`
+	Task      Task          `json:"task"`
+	// Labels are the names of the classes of a classification
+	Labels []string `json:"labels,omitempty"`
+	// Sizes are the number of inputs and the number of neurons of each layer
+	Sizes []int `json:"sizes"`
+	// Activations are the activation functions of each layer, the last is the output activation
+	Activations Activations `json:"activations"`
+	Loss        Loss        `json:"loss"`
+	// Weights are the weight matrix and bias vector of each layer
+	Weights []Tensor `json:"weights"`
+}
+
+// Model compresses the trained network of the checkpoint into a model
+func (c *Checkpoint) Model() (*Model, error) {
+	network, err := c.Network()
+	if err != nil {
+		return nil, err
+	}
+	model := &Model{
+		Version:   ModelVersion,
+		Dataset:   c.Dataset,
+		Mode:      c.Mode,
+		Optimizer: c.Optimizer,
+		Seed:      c.Seed,
+		Epochs:    c.Epoch,
+		Converged: c.Converged,
+		Config:    c.Config,
+		In:        network.In,
+		Out:       network.Out,
+		Task:      network.Dataset.Task(),
+		Sizes:     []int{network.In},
+		Loss:      network.Loss,
+	}
+	if model.Task == TaskClassification {
+		model.Labels = network.Dataset.Labels()
+	}
+	for i, weight := range Compress(network.Weights...) {
+		model.Weights = append(model.Weights, Tensor{Shape: weight.S, Values: weight.X})
+		if i%2 == 1 {
+			model.Sizes = append(model.Sizes, weight.S[0])
+		}
+	}
+	// the default activations are replaced with the ones they stand for
+	layers := len(model.Sizes) - 1
+	for i := 0; i < layers; i++ {
+		activation := network.Activations.Layer(i)
+		if activation == ActivationDefault {
+			activation = ActivationSigmoid
+			if i == layers-1 {
+				activation = network.Dataset.Output()
+			}
+		}
+		model.Activations = append(model.Activations, activation)
+	}
+	return model, nil
+}
+
+// Save writes the model to a file
+func (m *Model) Save(name string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(name+".tmp", data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
+
+// LoadModel reads a model from a file
+func LoadModel(name string) (*Model, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	model := Model{}
+	err = json.Unmarshal(data, &model)
+	if err != nil {
+		return nil, err
+	}
+	if model.Version != ModelVersion {
+		return nil, fmt.Errorf("model version %d is not %d", model.Version, ModelVersion)
+	}
+	return &model, nil
+}
+
+// Classifier is true if the model is a classification
+func (m *Model) Classifier() bool {
+	return m.Task == TaskClassification
+}
+
+// Output is the activation function of the output layer
+func (m *Model) Output() Activation {
+	return m.Activations.Layer(len(m.Activations) - 1)
+}
+
+// modelDataset is the dataset of a loaded model, it only has what is needed for inference
+type modelDataset struct {
+	model *Model
+}
+
+// Name is the name of the dataset the model was trained on
+func (d modelDataset) Name() string {
+	return d.model.Dataset
+}
+
+// Data is empty because the data isn't stored in the model
+func (d modelDataset) Data() []Datum {
+	return nil
+}
+
+// Activation is the output activation of the model
+func (d modelDataset) Activation(o *Operators, a tf32.Meta) tf32.Meta {
+	return d.model.Output().ApplyWith(o, a)
+}
+
+// DefaultLoss is the loss of the model
+func (d modelDataset) DefaultLoss() Loss {
+	return d.model.Loss
+}
+
+// Classes is the number of labels
+func (d modelDataset) Classes() int {
+	return len(d.model.Labels)
+}
+
+// Label is the most probable class
+func (d modelDataset) Label(output []float32) int {
+	max, label := float32(-math.MaxFloat32), 0
+	for i, value := range output {
+		if value > max {
+			max, label = value, i
+		}
+	}
+	return label
+}
+
+// Output is the output activation of the model
+func (d modelDataset) Output() Activation {
+	return d.model.Output()
+}
+
+// Task is the task of the model
+func (d modelDataset) Task() Task {
+	return d.model.Task
+}
+
+// Labels are the names of the classes
+func (d modelDataset) Labels() []string {
+	return d.model.Labels
+}
+
+// Network rebuilds the compressed network of the model for inference
+func (m *Model) Network() (*Network, error) {
+	layers := len(m.Sizes) - 1
+	if layers < 1 || m.Sizes[0] != m.In || m.Sizes[layers] != m.Out {
+		return nil, fmt.Errorf("model has the sizes %v and should have %d inputs and %d outputs", m.Sizes, m.In, m.Out)
+	}
+	if len(m.Weights) != 2*layers {
+		return nil, fmt.Errorf("model has %d weights and should have a weight and bias for each of the %d layers", len(m.Weights), layers)
+	}
+	if len(m.Activations) != layers {
+		return nil, fmt.Errorf("model has %d activations and should have one for each of the %d layers", len(m.Activations), layers)
+	}
+	if m.Loss == LossDefault {
+		return nil, fmt.Errorf("model doesn't have a loss")
+	}
+	if m.Task == TaskClassification && len(m.Labels) != m.Out {
+		return nil, fmt.Errorf("model has %d labels and should have one for each of the %d outputs", len(m.Labels), m.Out)
+	}
+	network := &Network{
+		Dataset:     modelDataset{m},
+		Mode:        m.Mode,
+		Activations: m.Activations,
+		Loss:        m.Loss,
+		In:          m.In,
+		Out:         m.Out,
+	}
+	for i, weight := range m.Weights {
+		// each weight matrix maps the outputs of the previous layer to the biases of its layer
+		shape := []int{m.Sizes[i/2], m.Sizes[i/2+1]}
+		if i%2 == 1 {
+			shape = []int{m.Sizes[i/2+1], 1}
+		}
+		if len(weight.Shape) != 2 || weight.Shape[0] != shape[0] || weight.Shape[1] != shape[1] ||
+			len(weight.Values) != shape[0]*shape[1] {
+			return nil, fmt.Errorf("model weight %d has the shape %v and should have %v", i, weight.Shape, shape)
+		}
+		v := tf32.NewV(weight.Shape...)
+		v.Set(weight.Values)
+		network.Parameters = append(network.Parameters, &v)
+		network.Weights = append(network.Weights, v.Meta())
+		network.Inference = append(network.Inference, v.Meta())
+	}
+	return network, nil
+}
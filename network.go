@@ -0,0 +1,196 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math/rand"
+
+	"github.com/pointlander/gradient/tf32"
+)
+
+// Network is a multilayer network with normal, inception or dct weights
+type Network struct {
+	Dataset       Dataset
+	Mode          Mode
+	Activations   Activations
+	Loss          Loss
+	In, Out       int
+	Input, Output *tf32.V
+	// Parameters are the trainable parameters
+	Parameters []*tf32.V
+	// Zero are the constants that need their partial derivatives zeroed
+	Zero []*tf32.V
+	// Weights are the weight and bias expressions of each layer
+	Weights []tf32.Meta
+	// Inference are the weight and bias expressions without the partial derivatives
+	Inference []tf32.Meta
+	// Factors are the factor pairs of each weight and bias of an inception network
+	Factors [][]*tf32.V
+	Layer   tf32.Meta
+	Cost    tf32.Meta
+}
+
+// NewNetwork creates a network for a dataset with in inputs and out outputs
+// The parameters are initialized with rnd or to zero if rnd is nil
+func NewNetwork(rnd *rand.Rand, dataset Dataset, config Config, mode Mode, in, out, batchSize int) *Network {
+	sizes := append(append([]int{in}, config.Widths()...), out)
+	layers := len(sizes) - 1
+
+	input, output := tf32.NewV(in, batchSize), tf32.NewV(out, batchSize)
+	parameters, zero, factors := []*tf32.V{}, []*tf32.V{}, make([][]*tf32.V, 2*layers)
+	// the weight expressions are built with the training and the inference operators
+	expressions := make([]func(o *Operators) tf32.Meta, 0, 2*layers)
+	constant := func(v *tf32.V) func(o *Operators) tf32.Meta {
+		meta := v.Meta()
+		return func(o *Operators) tf32.Meta {
+			return meta
+		}
+	}
+	// initializers are the initializers of the parameters
+	initializers, base := []Initializer{}, []Initializer{config.WeightInit, config.BiasInit}
+	for i := 0; i < layers; i++ {
+		w, b := tf32.NewV(sizes[i], sizes[i+1]), tf32.NewV(sizes[i+1])
+		parameters = append(parameters, &w, &b)
+		initializers = append(initializers, base...)
+		expressions = append(expressions, constant(&w), constant(&b))
+	}
+	switch mode {
+	case ModeDCT:
+		extra, inits := []*tf32.V{}, []Initializer{}
+		for i := 0; i < layers; i++ {
+			if !config.IsFactorized(i) {
+				continue
+			}
+			for j, size := range []int{sizes[i], sizes[i+1]} {
+				t, tt := DCT2(size)
+				v := tf32.NewV(parameters[2*i+j].S...)
+				weight, tm, ttm, vm := expressions[2*i+j], t.Meta(), tt.Meta(), v.Meta()
+				expressions[2*i+j] = func(o *Operators) tf32.Meta {
+					return o.Add(o.Mul(ttm, o.T(o.Mul(weight(o), tm))), vm)
+				}
+				zero = append(zero, &t, &tt)
+				extra, inits = append(extra, &v), append(inits, base[j])
+			}
+		}
+		parameters, initializers = append(parameters, extra...), append(initializers, inits...)
+	case ModeInception:
+		for i := 0; i < 2*layers; i++ {
+			if !config.IsFactorized(i / 2) {
+				continue
+			}
+			// the weights are multiplied by the fan in and the biases by the fan out
+			fan := sizes[i/2+i%2]
+			for j := 0; j < config.Depth; j++ {
+				a, b := tf32.NewV(fan, fan), tf32.NewV(parameters[i].S...)
+				weight, am, bm := expressions[i], a.Meta(), b.Meta()
+				expressions[i] = func(o *Operators) tf32.Meta {
+					return o.Add(o.Mul(am, bm), weight(o))
+				}
+				parameters, factors[i] = append(parameters, &a, &b), append(factors[i], &a, &b)
+				initializers = append(initializers, config.FactorAInit, config.FactorBInit)
+			}
+		}
+	}
+
+	scale := config.Scale()
+	for i, p := range parameters {
+		if rnd == nil {
+			InitializerZeros.Initialize(nil, p, 0)
+			continue
+		}
+		initializers[i].Initialize(rnd, p, scale)
+	}
+
+	weights, inference := make([]tf32.Meta, len(expressions)), make([]tf32.Meta, len(expressions))
+	for i, expression := range expressions {
+		weights[i], inference[i] = expression(Training), expression(Inference)
+	}
+	n := &Network{
+		Dataset:     dataset,
+		Mode:        mode,
+		Activations: config.Activations,
+		Loss:        config.Loss,
+		In:          in,
+		Out:         out,
+		Input:       &input,
+		Output:      &output,
+		Parameters:  parameters,
+		Zero:        zero,
+		Weights:     weights,
+		Inference:   inference,
+		Factors:     factors,
+	}
+	n.Layer = n.Forward(input.Meta())
+	if n.Loss == LossDefault {
+		n.Loss = dataset.DefaultLoss()
+	}
+	n.Cost = n.Loss.Cost(n.Layer, output.Meta())
+	return n
+}
+
+// Forward connects the layers of the network to an input
+func (n *Network) Forward(input tf32.Meta) tf32.Meta {
+	return n.Connect(n.Weights, input)
+}
+
+// Connect connects layers with the given weight and bias expressions to an input
+func (n *Network) Connect(weights []tf32.Meta, input tf32.Meta) tf32.Meta {
+	return n.ConnectWith(Training, weights, input)
+}
+
+// ConnectWith connects layers with the given operators, weight and bias expressions to an input
+func (n *Network) ConnectWith(o *Operators, weights []tf32.Meta, input tf32.Meta) tf32.Meta {
+	layer, layers := input, len(weights)/2
+	for i := 0; i < len(weights); i += 2 {
+		layer = n.Activate(o, i/2, layers, o.Add(o.Mul(weights[i], layer), weights[i+1]))
+	}
+	return layer
+}
+
+// Activate applies the activation function of a layer of layers, the default of the output layer is the activation of the dataset
+func (n *Network) Activate(o *Operators, layer, layers int, a tf32.Meta) tf32.Meta {
+	activation := n.Activations.Layer(layer)
+	if layer == layers-1 && activation == ActivationDefault {
+		return n.Dataset.Activation(o, a)
+	}
+	return activation.ApplyWith(o, a)
+}
+
+// Evaluate computes the cost and outputs of the network for all of the data at once
+// It doesn't compute the partial derivatives, so a network can be evaluated by many goroutines at the same time
+func (n *Network) Evaluate(data []Datum) (cost float32, outputs []float32) {
+	input, output := tf32.NewV(n.In, len(data)), tf32.NewV(n.Out, len(data))
+	for _, datum := range data {
+		input.X = append(input.X, datum.Input...)
+		output.X = append(output.X, datum.Output...)
+	}
+	layer := n.ConnectWith(Inference, n.Inference, input.Meta())
+	layer(func(a *tf32.V) {
+		outputs = a.X
+	})
+	n.Loss.CostWith(Inference, layer, output.Meta())(func(a *tf32.V) {
+		cost = a.X[0]
+	})
+	return cost, outputs
+}
+
+// InferenceCost is the cost of the input and output of the network without the partial derivatives
+func (n *Network) InferenceCost() tf32.Meta {
+	return n.Loss.CostWith(Inference, n.ConnectWith(Inference, n.Inference, n.Input.Meta()), n.Output.Meta())
+}
+
+// Reinit draws a factor pair again from the factor initializers of the config
+func Reinit(rnd *rand.Rand, config Config, pair []*tf32.V) {
+	for i, initializer := range []Initializer{config.FactorAInit, config.FactorBInit} {
+		pair[i].X = pair[i].X[:0]
+		initializer.Initialize(rnd, pair[i], config.Scale())
+	}
+}
+
+// Infer computes the output of the network for a single input
+func (n *Network) Infer(input []float32) []float32 {
+	_, outputs := n.Evaluate([]Datum{{Input: input, Output: make([]float32, n.Out)}})
+	return outputs
+}
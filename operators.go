@@ -0,0 +1,84 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/pointlander/gradient/tf32"
+)
+
+// Operators are the operators of a tf32 context
+// The operators of an inference only context don't compute the partial derivatives,
+// so graphs built with them can be evaluated at the same time as other graphs are trained
+type Operators struct {
+	Context *tf32.Context
+	// Add adds two tensors
+	Add func(a, b tf32.Meta) tf32.Meta
+	// Mul multiplies two tensors
+	Mul func(a, b tf32.Meta) tf32.Meta
+	// Sigmoid is the sigmoid of a tensor
+	Sigmoid func(a tf32.Meta) tf32.Meta
+	// TanH is the hyperbolic tangent of a tensor
+	TanH func(a tf32.Meta) tf32.Meta
+	// Avg is the average of a tensor
+	Avg func(a tf32.Meta) tf32.Meta
+	// T is the transpose of a matrix
+	T func(a tf32.Meta) tf32.Meta
+	// Normalize is the tf32 softmax of each column, its derivative is only the diagonal of the jacobian
+	Normalize func(a tf32.Meta) tf32.Meta
+	// Quadratic computes the quadratic cost of each column
+	Quadratic func(a, b tf32.Meta) tf32.Meta
+	// CrossEntropy computes the cross entropy of each column
+	CrossEntropy func(a, b tf32.Meta) tf32.Meta
+
+	// ReLU is the rectified linear unit
+	ReLU func(a tf32.Meta) tf32.Meta
+	// LeakyReLU is the rectified linear unit with a small slope for negative inputs
+	LeakyReLU func(a tf32.Meta) tf32.Meta
+	// Softplus is a smooth rectified linear unit
+	Softplus func(a tf32.Meta) tf32.Meta
+	// GELU is the tanh approximation of the gaussian error linear unit
+	GELU func(a tf32.Meta) tf32.Meta
+	// Softmax is the softmax function of each column
+	Softmax func(a tf32.Meta) tf32.Meta
+
+	// MSE computes the mean squared error of each column
+	MSE func(a, b tf32.Meta) tf32.Meta
+	// MAE computes the mean absolute error of each column
+	MAE func(a, b tf32.Meta) tf32.Meta
+	// Huber computes the mean huber loss of each column
+	Huber func(a, b tf32.Meta) tf32.Meta
+	// CategoricalCrossEntropy computes the cross entropy of each column
+	CategoricalCrossEntropy func(a, b tf32.Meta) tf32.Meta
+	// Hinge computes the mean hinge loss of each column
+	Hinge func(a, b tf32.Meta) tf32.Meta
+	// Focal computes the binary focal loss with a gamma of 2 of each column
+	Focal func(a, b tf32.Meta) tf32.Meta
+}
+
+// NewOperators creates the operators of a context
+func NewOperators(context *tf32.Context) *Operators {
+	o := &Operators{
+		Context:      context,
+		Add:          tf32.B(context.Add),
+		Mul:          tf32.B(context.Mul),
+		Sigmoid:      tf32.U(context.Sigmoid),
+		TanH:         tf32.U(context.TanH),
+		Avg:          tf32.U(context.Avg),
+		T:            tf32.U(context.T),
+		Normalize:    tf32.U(context.Softmax),
+		Quadratic:    tf32.B(context.Quadratic),
+		CrossEntropy: tf32.B(context.CrossEntropy),
+	}
+	o.activations()
+	o.losses()
+	return o
+}
+
+var (
+	// Training are the operators of the static context of tf32
+	Training = NewOperators(&tf32.Static)
+	// Inference are the operators of an inference only context
+	Inference = NewOperators(&tf32.Context{InferenceOnly: true})
+)
@@ -0,0 +1,421 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/pointlander/gradient/tf32"
+)
+
+// Hyperparameters are the hyperparameters of the optimizers
+type Hyperparameters struct {
+	// Eta is the learning rate of static, momentum, nesterov and adagrad
+	Eta float32 `json:"eta"`
+	// Alpha is the momentum of momentum and nesterov
+	Alpha float32 `json:"alpha"`
+	// Rate is the learning rate of rmsprop and the adam optimizers
+	Rate float32 `json:"rate"`
+	// Beta1 is the decay rate of the first adam moment
+	Beta1 float32 `json:"beta1"`
+	// Beta2 is the decay rate of the second adam moment
+	Beta2 float32 `json:"beta2"`
+	// Epsilon prevents division by zero
+	Epsilon float32 `json:"epsilon"`
+	// Rho is the decay rate of rmsprop and adadelta
+	Rho float32 `json:"rho"`
+	// Decay is the weight decay of adamw
+	Decay float32 `json:"decay"`
+	// Schedule is the learning rate schedule
+	Schedule ScheduleType `json:"schedule"`
+	// Warmup is the number of epochs of linear learning rate warmup
+	Warmup int `json:"warmup"`
+	// Gamma is the learning rate decay of the step, exponential and plateau schedules
+	Gamma float32 `json:"gamma"`
+	// StepSize is the number of epochs between decays of the step and exponential schedules
+	StepSize int `json:"step_size"`
+	// Period is the number of epochs of the first cosine cycle
+	Period int `json:"period"`
+	// PeriodMult is the growth of the cosine cycle after each restart
+	PeriodMult int `json:"period_mult"`
+	// MinRate is the smallest learning rate multiplier of the cosine and one cycle schedules
+	MinRate float32 `json:"min_rate"`
+	// Patience is the number of epochs without improvement before the plateau schedule decays
+	Patience int `json:"patience"`
+}
+
+// DefaultHyperparameters returns the default hyperparameters with learning rate eta
+func DefaultHyperparameters(eta float32) Hyperparameters {
+	return Hyperparameters{
+		Eta:        eta,
+		Alpha:      .1,
+		Rate:       .001,
+		Beta1:      .9,
+		Beta2:      .999,
+		Epsilon:    1e-8,
+		Rho:        .9,
+		Decay:      .01,
+		Schedule:   ScheduleConstant,
+		Gamma:      .5,
+		StepSize:   1000,
+		Period:     1000,
+		PeriodMult: 2,
+		MinRate:    .01,
+		Patience:   100,
+	}
+}
+
+// Optimizer is an update rule that owns its per parameter state
+type Optimizer interface {
+	// Step updates the parameters with their gradients multiplied by scaling
+	// rate multiplies the learning rate and epoch is the current epoch starting from zero
+	Step(parameters []*tf32.V, scaling, rate float32, epoch int)
+}
+
+// OptimizerType an optimizer type
+type OptimizerType int
+
+const (
+	// OptimizerStatic is a static learning optimizer
+	OptimizerStatic OptimizerType = iota
+	// OptimizerMomentum basic optimizer
+	OptimizerMomentum
+	// OptimizerAdam the adam optimizer
+	OptimizerAdam
+	// OptimizerNesterov the nesterov accelerated gradient optimizer
+	OptimizerNesterov
+	// OptimizerRMSProp the rmsprop optimizer
+	OptimizerRMSProp
+	// OptimizerAdagrad the adagrad optimizer
+	OptimizerAdagrad
+	// OptimizerAdadelta the adadelta optimizer
+	OptimizerAdadelta
+	// OptimizerAdamW the adam optimizer with decoupled weight decay
+	OptimizerAdamW
+	// OptimizerAMSGrad the amsgrad variant of adam
+	OptimizerAMSGrad
+)
+
+// Optimizers the optimizers
+var Optimizers = [...]OptimizerType{
+	OptimizerStatic,
+	OptimizerMomentum,
+	OptimizerAdam,
+	OptimizerNesterov,
+	OptimizerRMSProp,
+	OptimizerAdagrad,
+	OptimizerAdadelta,
+	OptimizerAdamW,
+	OptimizerAMSGrad,
+}
+
+// Converts the optimzer to a string
+func (o OptimizerType) String() string {
+	switch o {
+	case OptimizerStatic:
+		return "static"
+	case OptimizerMomentum:
+		return "momentum"
+	case OptimizerAdam:
+		return "adam"
+	case OptimizerNesterov:
+		return "nesterov"
+	case OptimizerRMSProp:
+		return "rmsprop"
+	case OptimizerAdagrad:
+		return "adagrad"
+	case OptimizerAdadelta:
+		return "adadelta"
+	case OptimizerAdamW:
+		return "adamw"
+	case OptimizerAMSGrad:
+		return "amsgrad"
+	}
+	return "unknown"
+}
+
+// Set sets the optimizer from its name
+func (o *OptimizerType) Set(name string) error {
+	for _, optimizer := range Optimizers {
+		if optimizer.String() == name {
+			*o = optimizer
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown optimizer %s", name)
+}
+
+// MarshalText converts the optimizer to its name
+func (o OptimizerType) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// UnmarshalText sets the optimizer from its name
+func (o *OptimizerType) UnmarshalText(text []byte) error {
+	return o.Set(string(text))
+}
+
+// UsesRate is true for rmsprop and the adam optimizers, which have the learning rate Rate instead of Eta
+func (o OptimizerType) UsesRate() bool {
+	switch o {
+	case OptimizerRMSProp, OptimizerAdam, OptimizerAdamW, OptimizerAMSGrad:
+		return true
+	}
+	return false
+}
+
+// New creates an optimizer of this type with empty state
+func (o OptimizerType) New(h Hyperparameters) Optimizer {
+	switch o {
+	case OptimizerStatic:
+		return &Static{Hyperparameters: h}
+	case OptimizerMomentum:
+		return &Momentum{Hyperparameters: h}
+	case OptimizerAdam:
+		return &Adam{Hyperparameters: h}
+	case OptimizerNesterov:
+		return &Nesterov{Hyperparameters: h}
+	case OptimizerRMSProp:
+		return &RMSProp{Hyperparameters: h}
+	case OptimizerAdagrad:
+		return &Adagrad{Hyperparameters: h}
+	case OptimizerAdadelta:
+		return &Adadelta{Hyperparameters: h}
+	case OptimizerAdamW:
+		return &AdamW{Hyperparameters: h}
+	case OptimizerAMSGrad:
+		return &AMSGrad{Hyperparameters: h}
+	}
+	panic("unknown optimizer")
+}
+
+// moments allocates a zeroed moment for each parameter
+func moments(parameters []*tf32.V) [][]float32 {
+	m := make([][]float32, len(parameters))
+	for i, p := range parameters {
+		m[i] = make([]float32, len(p.X))
+	}
+	return m
+}
+
+// Clip is the scaling that clips the norm of the gradient of the parameters to 1
+func Clip(parameters []*tf32.V) float32 {
+	norm := float32(0)
+	for _, p := range parameters {
+		for _, d := range p.D {
+			norm += d * d
+		}
+	}
+	norm = sqrt(norm)
+	if norm > 1 {
+		return 1 / norm
+	}
+	return 1
+}
+
+// Static is gradient descent with a static learning rate
+type Static struct {
+	Hyperparameters
+}
+
+// Step updates the parameters
+func (o *Static) Step(parameters []*tf32.V, scaling, rate float32, epoch int) {
+	eta := o.Eta * rate
+	for _, p := range parameters {
+		for l, d := range p.D {
+			d *= scaling
+			p.X[l] -= eta * d
+		}
+	}
+}
+
+// Momentum is gradient descent with momentum
+type Momentum struct {
+	Hyperparameters
+	Deltas [][]float32
+}
+
+// Step updates the parameters
+func (o *Momentum) Step(parameters []*tf32.V, scaling, rate float32, epoch int) {
+	eta := o.Eta * rate
+	if o.Deltas == nil {
+		o.Deltas = moments(parameters)
+	}
+	for k, p := range parameters {
+		for l, d := range p.D {
+			d *= scaling
+			o.Deltas[k][l] = o.Alpha*o.Deltas[k][l] - eta*d
+			p.X[l] += o.Deltas[k][l]
+		}
+	}
+}
+
+// Nesterov is gradient descent with nesterov momentum
+type Nesterov struct {
+	Hyperparameters
+	Deltas [][]float32
+}
+
+// Step updates the parameters
+func (o *Nesterov) Step(parameters []*tf32.V, scaling, rate float32, epoch int) {
+	eta := o.Eta * rate
+	if o.Deltas == nil {
+		o.Deltas = moments(parameters)
+	}
+	for k, p := range parameters {
+		for l, d := range p.D {
+			d *= scaling
+			previous := o.Deltas[k][l]
+			o.Deltas[k][l] = o.Alpha*previous - eta*d
+			p.X[l] += -o.Alpha*previous + (1+o.Alpha)*o.Deltas[k][l]
+		}
+	}
+}
+
+// Adam is the adam optimizer
+// https://arxiv.org/abs/1412.6980
+type Adam struct {
+	Hyperparameters
+	M, V [][]float32
+}
+
+// Step updates the parameters
+func (o *Adam) Step(parameters []*tf32.V, scaling, rate float32, epoch int) {
+	eta := o.Rate * rate
+	if o.M == nil {
+		o.M, o.V = moments(parameters), moments(parameters)
+	}
+	t := float32(epoch + 1)
+	for k, p := range parameters {
+		for l, d := range p.D {
+			d *= scaling
+			o.M[k][l] = o.Beta1*o.M[k][l] + (1-o.Beta1)*d
+			o.V[k][l] = o.Beta2*o.V[k][l] + (1-o.Beta2)*d*d
+			mCorrected := o.M[k][l] / (1 - pow(o.Beta1, t))
+			vCorrected := o.V[k][l] / (1 - pow(o.Beta2, t))
+			p.X[l] -= eta * mCorrected / (sqrt(vCorrected) + o.Epsilon)
+		}
+	}
+}
+
+// RMSProp divides the learning rate by a running average of the gradient magnitude
+type RMSProp struct {
+	Hyperparameters
+	V [][]float32
+}
+
+// Step updates the parameters
+func (o *RMSProp) Step(parameters []*tf32.V, scaling, rate float32, epoch int) {
+	eta := o.Rate * rate
+	if o.V == nil {
+		o.V = moments(parameters)
+	}
+	for k, p := range parameters {
+		for l, d := range p.D {
+			d *= scaling
+			o.V[k][l] = o.Rho*o.V[k][l] + (1-o.Rho)*d*d
+			p.X[l] -= eta * d / (sqrt(o.V[k][l]) + o.Epsilon)
+		}
+	}
+}
+
+// Adagrad divides the learning rate by the accumulated gradient magnitude
+type Adagrad struct {
+	Hyperparameters
+	G [][]float32
+}
+
+// Step updates the parameters
+func (o *Adagrad) Step(parameters []*tf32.V, scaling, rate float32, epoch int) {
+	eta := o.Eta * rate
+	if o.G == nil {
+		o.G = moments(parameters)
+	}
+	for k, p := range parameters {
+		for l, d := range p.D {
+			d *= scaling
+			o.G[k][l] += d * d
+			p.X[l] -= eta * d / (sqrt(o.G[k][l]) + o.Epsilon)
+		}
+	}
+}
+
+// Adadelta adapts the step size from running averages of the gradients and updates
+// https://arxiv.org/abs/1212.5701
+type Adadelta struct {
+	Hyperparameters
+	G, X [][]float32
+}
+
+// Step updates the parameters
+func (o *Adadelta) Step(parameters []*tf32.V, scaling, rate float32, epoch int) {
+	if o.G == nil {
+		o.G, o.X = moments(parameters), moments(parameters)
+	}
+	for k, p := range parameters {
+		for l, d := range p.D {
+			d *= scaling
+			o.G[k][l] = o.Rho*o.G[k][l] + (1-o.Rho)*d*d
+			delta := -sqrt(o.X[k][l]+o.Epsilon) / sqrt(o.G[k][l]+o.Epsilon) * d
+			o.X[k][l] = o.Rho*o.X[k][l] + (1-o.Rho)*delta*delta
+			p.X[l] += rate * delta
+		}
+	}
+}
+
+// AdamW is adam with decoupled weight decay
+// https://arxiv.org/abs/1711.05101
+type AdamW struct {
+	Hyperparameters
+	M, V [][]float32
+}
+
+// Step updates the parameters
+func (o *AdamW) Step(parameters []*tf32.V, scaling, rate float32, epoch int) {
+	eta := o.Rate * rate
+	if o.M == nil {
+		o.M, o.V = moments(parameters), moments(parameters)
+	}
+	t := float32(epoch + 1)
+	for k, p := range parameters {
+		for l, d := range p.D {
+			d *= scaling
+			o.M[k][l] = o.Beta1*o.M[k][l] + (1-o.Beta1)*d
+			o.V[k][l] = o.Beta2*o.V[k][l] + (1-o.Beta2)*d*d
+			mCorrected := o.M[k][l] / (1 - pow(o.Beta1, t))
+			vCorrected := o.V[k][l] / (1 - pow(o.Beta2, t))
+			p.X[l] -= eta * (mCorrected/(sqrt(vCorrected)+o.Epsilon) + o.Decay*p.X[l])
+		}
+	}
+}
+
+// AMSGrad is adam using the maximum of the second moments
+// https://openreview.net/forum?id=ryQu7f-RZ
+type AMSGrad struct {
+	Hyperparameters
+	M, V, VMax [][]float32
+}
+
+// Step updates the parameters
+func (o *AMSGrad) Step(parameters []*tf32.V, scaling, rate float32, epoch int) {
+	eta := o.Rate * rate
+	if o.M == nil {
+		o.M, o.V, o.VMax = moments(parameters), moments(parameters), moments(parameters)
+	}
+	t := float32(epoch + 1)
+	for k, p := range parameters {
+		for l, d := range p.D {
+			d *= scaling
+			o.M[k][l] = o.Beta1*o.M[k][l] + (1-o.Beta1)*d
+			o.V[k][l] = o.Beta2*o.V[k][l] + (1-o.Beta2)*d*d
+			if o.V[k][l] > o.VMax[k][l] {
+				o.VMax[k][l] = o.V[k][l]
+			}
+			mCorrected := o.M[k][l] / (1 - pow(o.Beta1, t))
+			vCorrected := o.VMax[k][l] / (1 - pow(o.Beta2, t))
+			p.X[l] -= eta * mCorrected / (sqrt(vCorrected) + o.Epsilon)
+		}
+	}
+}
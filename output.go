@@ -0,0 +1,462 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Record is the result of training with one seed
+type Record struct {
+	Dataset   string        `json:"dataset"`
+	Seed      int64         `json:"seed"`
+	Mode      Mode          `json:"mode"`
+	Optimizer OptimizerType `json:"optimizer"`
+	Batch     int           `json:"batch"`
+	Context   bool          `json:"context"`
+	Converged bool          `json:"converged"`
+	Epochs    int           `json:"epochs"`
+	Misses    int           `json:"misses"`
+	Accuracy  *float64      `json:"accuracy"`
+	// Precision and Recall are of each class, null if it is undefined for the class
+	Precision       []*float64 `json:"precision"`
+	Recall          []*float64 `json:"recall"`
+	Confusion       Confusion  `json:"confusion"`
+	Costs           Costs      `json:"costs"`
+	ValidationCosts Costs      `json:"validation_costs"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// Costs are the costs of each epoch
+type Costs []float32
+
+// MarshalJSON encodes the costs with NaN and infinity, which can't be encoded in json, as null
+func (c Costs) MarshalJSON() ([]byte, error) {
+	if c == nil {
+		return []byte("null"), nil
+	}
+	data := []byte{'['}
+	for i, cost := range c {
+		if i > 0 {
+			data = append(data, ',')
+		}
+		if math.IsNaN(float64(cost)) || math.IsInf(float64(cost), 0) {
+			data = append(data, "null"...)
+			continue
+		}
+		data = strconv.AppendFloat(data, float64(cost), 'g', -1, 32)
+	}
+	return append(data, ']'), nil
+}
+
+// StatisticsRecord is the statistics of training with many seeds
+type StatisticsRecord struct {
+	Dataset                string        `json:"dataset"`
+	Mode                   Mode          `json:"mode"`
+	Optimizer              OptimizerType `json:"optimizer"`
+	Batch                  int           `json:"batch"`
+	Context                bool          `json:"context"`
+	Count                  int           `json:"count"`
+	Converged              int           `json:"converged"`
+	ConvergenceProbability float64       `json:"convergence_probability"`
+	AverageEpochs          *float64      `json:"average_epochs"`
+	MedianEpochs           float64       `json:"median_epochs"`
+	StdDevEpochs           float64       `json:"stddev_epochs"`
+	P25Epochs              float64       `json:"p25_epochs"`
+	P75Epochs              float64       `json:"p75_epochs"`
+	CILower                float64       `json:"ci_lower"`
+	CIUpper                float64       `json:"ci_upper"`
+	AverageAccuracy        *float64      `json:"average_accuracy"`
+	// Precision and Recall are of each class over the summed confusion matrix of the test sets
+	Precision []*float64 `json:"precision"`
+	Recall    []*float64 `json:"recall"`
+	Confusion Confusion  `json:"confusion"`
+}
+
+// ComparisonRecord is the paired comparison of a mode to a baseline mode
+type ComparisonRecord struct {
+	Dataset   string        `json:"dataset"`
+	Optimizer OptimizerType `json:"optimizer"`
+	Batch     int           `json:"batch"`
+	Context   bool          `json:"context"`
+	Baseline  Mode          `json:"baseline"`
+	Mode      Mode          `json:"mode"`
+	Speedup   float64       `json:"speedup"`
+	W         float64       `json:"w"`
+	N         int           `json:"n"`
+	P         float64       `json:"p"`
+}
+
+// GenerationsRecord is the result of the genetic algorithm with one seed
+type GenerationsRecord struct {
+	Dataset     string `json:"dataset"`
+	Seed        int64  `json:"seed"`
+	Generations int    `json:"generations"`
+}
+
+// Output collects the results of the runners for writing to a file
+// The methods do nothing for a nil output
+type Output struct {
+	sync.Mutex
+	Results     []Record            `json:"results"`
+	Statistics  []StatisticsRecord  `json:"statistics"`
+	Comparisons []ComparisonRecord  `json:"comparisons"`
+	Generations []GenerationsRecord `json:"generations"`
+}
+
+// number converts NaN, which can't be encoded in json, to nil
+func number(x float64) *float64 {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return nil
+	}
+	return &x
+}
+
+// AddResult adds the result of training with one seed
+func (o *Output) AddResult(result Result) {
+	if o == nil {
+		return
+	}
+	record := Record{
+		Dataset:         result.Dataset,
+		Seed:            result.Seed,
+		Mode:            result.Mode,
+		Optimizer:       result.Optimizer,
+		Batch:           result.Batch,
+		Context:         result.Context,
+		Converged:       result.Converged,
+		Epochs:          len(result.Costs),
+		Misses:          result.Misses,
+		Costs:           result.Costs,
+		ValidationCosts: result.ValidationCosts,
+		Error:           result.Error,
+	}
+	if result.Confusion != nil {
+		record.Accuracy = number(result.Confusion.Accuracy())
+		record.Confusion = result.Confusion
+		for i := range result.Confusion {
+			record.Precision = append(record.Precision, number(result.Confusion.Precision(i)))
+			record.Recall = append(record.Recall, number(result.Confusion.Recall(i)))
+		}
+	}
+	o.Lock()
+	defer o.Unlock()
+	o.Results = append(o.Results, record)
+}
+
+// AddStatistics adds the statistics of training a dataset with many seeds
+func (o *Output) AddStatistics(dataset string, statistics ...Statistics) {
+	if o == nil {
+		return
+	}
+	o.Lock()
+	defer o.Unlock()
+	for i := range statistics {
+		s := &statistics[i]
+		record := StatisticsRecord{
+			Dataset:                dataset,
+			Mode:                   s.Mode,
+			Optimizer:              s.Optimizer,
+			Batch:                  s.Batch,
+			Context:                s.Context,
+			Count:                  s.Count,
+			Converged:              s.Converged,
+			ConvergenceProbability: s.ConvergenceProbability(),
+			MedianEpochs:           s.MedianEpochs(),
+			StdDevEpochs:           s.StdDevEpochs(),
+			P25Epochs:              s.PercentileEpochs(25),
+			P75Epochs:              s.PercentileEpochs(75),
+		}
+		record.CILower, record.CIUpper = s.ConfidenceInterval()
+		if s.Converged > 0 {
+			record.AverageEpochs = number(s.AverageEpochs())
+		}
+		if s.Tested > 0 {
+			record.AverageAccuracy = number(s.AverageAccuracy())
+			record.Confusion = s.Confusion
+			for _, precision := range s.Precision() {
+				record.Precision = append(record.Precision, number(precision))
+			}
+			for _, recall := range s.Recall() {
+				record.Recall = append(record.Recall, number(recall))
+			}
+		}
+		o.Statistics = append(o.Statistics, record)
+	}
+}
+
+// AddComparisons adds the paired comparisons of modes to a baseline mode
+func (o *Output) AddComparisons(dataset string, comparisons ...Comparison) {
+	if o == nil {
+		return
+	}
+	o.Lock()
+	defer o.Unlock()
+	for _, c := range comparisons {
+		o.Comparisons = append(o.Comparisons, ComparisonRecord{
+			Dataset:   dataset,
+			Optimizer: c.Other.Optimizer,
+			Batch:     c.Other.Batch,
+			Context:   c.Other.Context,
+			Baseline:  c.Baseline.Mode,
+			Mode:      c.Other.Mode,
+			Speedup:   c.Speedup,
+			W:         c.W,
+			N:         c.N,
+			P:         c.P,
+		})
+	}
+}
+
+// AddGenerations adds the number of generations the genetic algorithm took for a seed
+func (o *Output) AddGenerations(dataset string, seed int64, generations int) {
+	if o == nil {
+		return
+	}
+	o.Lock()
+	defer o.Unlock()
+	o.Generations = append(o.Generations, GenerationsRecord{
+		Dataset:     dataset,
+		Seed:        seed,
+		Generations: generations,
+	})
+}
+
+// OutputFormats are the extensions of the output formats
+var OutputFormats = [...]string{".json", ".jsonl", ".csv"}
+
+// OutputFile is the name of an output file, the extension of the name is the format
+type OutputFile string
+
+// String is the name of the output file
+func (f *OutputFile) String() string {
+	return string(*f)
+}
+
+// Set sets the name of the output file if it has the extension of an output format
+func (f *OutputFile) Set(name string) error {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, format := range OutputFormats {
+		if format == ext {
+			*f = OutputFile(name)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %s of %s, it should be json, jsonl or csv", filepath.Ext(name), name)
+}
+
+// Save writes the output to a file in the format of its extension: json, jsonl or csv
+// A json file has all of the records in one object and a jsonl file has one record per line with its type
+// The statistics, comparisons and generations of a csv file are written to files with
+// _statistics, _comparisons and _generations suffixes
+func (o *Output) Save(name string) error {
+	if o == nil {
+		return nil
+	}
+	o.Lock()
+	defer o.Unlock()
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		data, err := json.MarshalIndent(o, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFile(name, func(w *bufio.Writer) error {
+			_, err := w.Write(append(data, '\n'))
+			return err
+		})
+	case ".jsonl":
+		return writeFile(name, func(w *bufio.Writer) error {
+			encoder := json.NewEncoder(w)
+			for i := range o.Results {
+				err := encoder.Encode(struct {
+					Type string `json:"type"`
+					*Record
+				}{"result", &o.Results[i]})
+				if err != nil {
+					return err
+				}
+			}
+			for i := range o.Statistics {
+				err := encoder.Encode(struct {
+					Type string `json:"type"`
+					*StatisticsRecord
+				}{"statistics", &o.Statistics[i]})
+				if err != nil {
+					return err
+				}
+			}
+			for i := range o.Comparisons {
+				err := encoder.Encode(struct {
+					Type string `json:"type"`
+					*ComparisonRecord
+				}{"comparison", &o.Comparisons[i]})
+				if err != nil {
+					return err
+				}
+			}
+			for i := range o.Generations {
+				err := encoder.Encode(struct {
+					Type string `json:"type"`
+					*GenerationsRecord
+				}{"generations", &o.Generations[i]})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case ".csv":
+		base := strings.TrimSuffix(name, filepath.Ext(name))
+		if len(o.Results) > 0 {
+			err := writeCSV(name, o.resultsCSV())
+			if err != nil {
+				return err
+			}
+		}
+		if len(o.Statistics) > 0 {
+			err := writeCSV(base+"_statistics.csv", o.statisticsCSV())
+			if err != nil {
+				return err
+			}
+		}
+		if len(o.Comparisons) > 0 {
+			err := writeCSV(base+"_comparisons.csv", o.comparisonsCSV())
+			if err != nil {
+				return err
+			}
+		}
+		if len(o.Generations) > 0 {
+			return writeCSV(base+"_generations.csv", o.generationsCSV())
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %s", filepath.Ext(name))
+}
+
+func (o *Output) resultsCSV() [][]string {
+	rows := [][]string{{"dataset", "seed", "mode", "optimizer", "batch", "context", "converged",
+		"epochs", "misses", "accuracy", "precision", "recall", "confusion", "costs", "validation_costs", "error"}}
+	for _, r := range o.Results {
+		rows = append(rows, []string{r.Dataset, strconv.FormatInt(r.Seed, 10), r.Mode.String(),
+			r.Optimizer.String(), strconv.Itoa(r.Batch), strconv.FormatBool(r.Context),
+			strconv.FormatBool(r.Converged), strconv.Itoa(r.Epochs), strconv.Itoa(r.Misses),
+			formatNumber(r.Accuracy), formatNumbers(r.Precision), formatNumbers(r.Recall),
+			formatConfusion(r.Confusion), formatCosts(r.Costs), formatCosts(r.ValidationCosts), r.Error})
+	}
+	return rows
+}
+
+func (o *Output) statisticsCSV() [][]string {
+	rows := [][]string{{"dataset", "mode", "optimizer", "batch", "context", "count", "converged",
+		"convergence_probability", "average_epochs", "median_epochs", "stddev_epochs", "p25_epochs",
+		"p75_epochs", "ci_lower", "ci_upper", "average_accuracy", "precision", "recall", "confusion"}}
+	for _, s := range o.Statistics {
+		rows = append(rows, []string{s.Dataset, s.Mode.String(), s.Optimizer.String(),
+			strconv.Itoa(s.Batch), strconv.FormatBool(s.Context), strconv.Itoa(s.Count),
+			strconv.Itoa(s.Converged), formatNumber(&s.ConvergenceProbability),
+			formatNumber(s.AverageEpochs), formatNumber(&s.MedianEpochs), formatNumber(&s.StdDevEpochs),
+			formatNumber(&s.P25Epochs), formatNumber(&s.P75Epochs), formatNumber(&s.CILower),
+			formatNumber(&s.CIUpper), formatNumber(s.AverageAccuracy), formatNumbers(s.Precision),
+			formatNumbers(s.Recall), formatConfusion(s.Confusion)})
+	}
+	return rows
+}
+
+func (o *Output) comparisonsCSV() [][]string {
+	rows := [][]string{{"dataset", "optimizer", "batch", "context", "baseline", "mode", "speedup", "w", "n", "p"}}
+	for _, c := range o.Comparisons {
+		rows = append(rows, []string{c.Dataset, c.Optimizer.String(), strconv.Itoa(c.Batch),
+			strconv.FormatBool(c.Context), c.Baseline.String(), c.Mode.String(), formatNumber(&c.Speedup),
+			formatNumber(&c.W), strconv.Itoa(c.N), formatNumber(&c.P)})
+	}
+	return rows
+}
+
+func (o *Output) generationsCSV() [][]string {
+	rows := [][]string{{"dataset", "seed", "generations"}}
+	for _, g := range o.Generations {
+		rows = append(rows, []string{g.Dataset, strconv.FormatInt(g.Seed, 10), strconv.Itoa(g.Generations)})
+	}
+	return rows
+}
+
+// formatNumber formats a number for csv, nil is an empty field
+func formatNumber(x *float64) string {
+	if x == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*x, 'g', -1, 64)
+}
+
+// formatNumbers formats numbers as a space separated list for a csv field, nil is NaN
+func formatNumbers(numbers []*float64) string {
+	fields := make([]string, len(numbers))
+	for i, x := range numbers {
+		fields[i] = "NaN"
+		if x != nil {
+			fields[i] = formatNumber(x)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// formatConfusion formats a confusion matrix for a csv field, the rows are separated by semicolons
+func formatConfusion(confusion Confusion) string {
+	rows := make([]string, len(confusion))
+	for i, counts := range confusion {
+		fields := make([]string, len(counts))
+		for j, count := range counts {
+			fields[j] = strconv.Itoa(count)
+		}
+		rows[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(rows, ";")
+}
+
+// formatCosts formats costs as a space separated list for a csv field
+func formatCosts(costs []float32) string {
+	fields := make([]string, len(costs))
+	for i, cost := range costs {
+		fields[i] = strconv.FormatFloat(float64(cost), 'g', -1, 32)
+	}
+	return strings.Join(fields, " ")
+}
+
+func writeCSV(name string, rows [][]string) error {
+	return writeFile(name, func(w *bufio.Writer) error {
+		writer := csv.NewWriter(w)
+		err := writer.WriteAll(rows)
+		if err != nil {
+			return err
+		}
+		return writer.Error()
+	})
+}
+
+func writeFile(name string, write func(w *bufio.Writer) error) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
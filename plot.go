@@ -0,0 +1,243 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// CostQuantiles computes the pth percentiles of the costs of the seeds at each epoch
+// A run that stopped early keeps its last cost and the costs that aren't finite are skipped,
+// the percentile is NaN for an epoch without any finite costs
+func CostQuantiles(costs [][]float32, p ...float64) [][]float64 {
+	epochs := 0
+	for _, cost := range costs {
+		if len(cost) > epochs {
+			epochs = len(cost)
+		}
+	}
+	quantiles := make([][]float64, len(p))
+	for i := range quantiles {
+		quantiles[i] = make([]float64, epochs)
+	}
+	values := make([]float64, 0, len(costs))
+	for epoch := 0; epoch < epochs; epoch++ {
+		values = values[:0]
+		for _, cost := range costs {
+			if len(cost) == 0 {
+				continue
+			}
+			value := float64(cost[len(cost)-1])
+			if epoch < len(cost) {
+				value = float64(cost[epoch])
+			}
+			if !math.IsNaN(value) && !math.IsInf(value, 0) {
+				values = append(values, value)
+			}
+		}
+		for i := range quantiles {
+			quantiles[i][epoch] = math.NaN()
+			if len(values) > 0 {
+				quantiles[i][epoch] = Percentile(values, p[i])
+			}
+		}
+	}
+	return quantiles
+}
+
+// modeColor is the color of a mode, the modes are far apart in the palette
+func modeColor(mode Mode) color.RGBA {
+	return colors[2*int(mode)%len(colors)]
+}
+
+// plottable is true if a value can be drawn on a linear or log scale
+func plottable(value float64, log bool) bool {
+	return !math.IsNaN(value) && (!log || value > 0)
+}
+
+// costAxis labels the cost axis and sets its scale
+func costAxis(axis *plot.Axis, log bool) {
+	axis.Label.Text = "cost"
+	if log {
+		axis.Label.Text = "cost (log)"
+		axis.Scale, axis.Tick.Marker = plot.LogScale{}, plot.LogTicks{}
+	}
+}
+
+// PlotCosts plots the median cost of each epoch with bands between the 25th and 75th and the 10th and 90th percentiles
+// There is a tile for each optimizer, batch and context with a curve for each mode
+func PlotCosts(file string, statistics []Statistics, log bool) error {
+	type Group struct {
+		Name       string
+		Statistics []*Statistics
+	}
+	groups, names := []*Group{}, make(map[string]*Group)
+	for i := range statistics {
+		s := &statistics[i]
+		name := fmt.Sprintf("%s batch %d", s.Optimizer, s.Batch)
+		if s.Context {
+			name += " context"
+		}
+		group := names[name]
+		if group == nil {
+			group = &Group{Name: name}
+			names[name] = group
+			groups = append(groups, group)
+		}
+		group.Statistics = append(group.Statistics, s)
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	cols := 3
+	if len(groups) < cols {
+		cols = len(groups)
+	}
+	rows := (len(groups) + cols - 1) / cols
+	plots := make([][]*plot.Plot, rows)
+	for i := range plots {
+		plots[i] = make([]*plot.Plot, cols)
+	}
+	for i, group := range groups {
+		p, err := plot.New()
+		if err != nil {
+			return err
+		}
+		p.Title.Text = group.Name
+		p.X.Label.Text = "epoch"
+		costAxis(&p.Y, log)
+		p.Legend.Top = true
+
+		for _, s := range group.Statistics {
+			c := modeColor(s.Mode)
+			quantiles := CostQuantiles(s.Costs, 10, 25, 50, 75, 90)
+			// the outer band is lighter than the inner band
+			for j, alpha := range []uint8{0x30, 0x60} {
+				lower, upper := quantiles[j], quantiles[len(quantiles)-1-j]
+				above, below := plotter.XYs{}, plotter.XYs{}
+				for epoch := range lower {
+					if plottable(lower[epoch], log) && plottable(upper[epoch], log) {
+						above = append(above, plotter.XY{X: float64(epoch), Y: upper[epoch]})
+						below = append(below, plotter.XY{X: float64(epoch), Y: lower[epoch]})
+					}
+				}
+				if len(above) < 2 {
+					continue
+				}
+				for k := len(below) - 1; k >= 0; k-- {
+					above = append(above, below[k])
+				}
+				band, err := plotter.NewPolygon(above)
+				if err != nil {
+					return err
+				}
+				band.Color = color.NRGBA{R: c.R, G: c.G, B: c.B, A: alpha}
+				band.LineStyle.Width = 0
+				p.Add(band)
+			}
+
+			median := plotter.XYs{}
+			for epoch, value := range quantiles[2] {
+				if plottable(value, log) {
+					median = append(median, plotter.XY{X: float64(epoch), Y: value})
+				}
+			}
+			if len(median) == 0 {
+				continue
+			}
+			line, err := plotter.NewLine(median)
+			if err != nil {
+				return err
+			}
+			line.Color = c
+			line.Width = vg.Points(1.5)
+			p.Add(line)
+			p.Legend.Add(s.Mode.String(), line)
+		}
+		plots[i/cols][i%cols] = p
+	}
+
+	img := vgimg.New(vg.Length(cols)*4*vg.Inch, vg.Length(rows)*3*vg.Inch)
+	tiles := draw.Tiles{
+		Rows:      rows,
+		Cols:      cols,
+		PadTop:    vg.Millimeter,
+		PadBottom: vg.Millimeter,
+		PadLeft:   vg.Millimeter,
+		PadRight:  vg.Millimeter,
+		PadX:      2 * vg.Millimeter,
+		PadY:      2 * vg.Millimeter,
+	}
+	canvases := plot.Align(plots, tiles, draw.New(img))
+	for i := range plots {
+		for j, p := range plots[i] {
+			if p != nil {
+				p.Draw(canvases[i][j])
+			}
+		}
+	}
+	return savePNG(file, img)
+}
+
+// PlotEpochs plots a box plot of the epochs of the seeds that converged for each of the statistics
+// The first of the statistics is at the top and the statistics without any convergence are left out
+func PlotEpochs(file, title string, statistics []Statistics, log bool) error {
+	p, err := plot.New()
+	if err != nil {
+		return err
+	}
+	p.Title.Text = title
+	p.X.Label.Text = "epochs to convergence"
+	if log {
+		p.X.Label.Text = "epochs to convergence (log)"
+		p.X.Scale, p.X.Tick.Marker = plot.LogScale{}, plot.LogTicks{}
+	}
+
+	names := []string{}
+	for i := len(statistics) - 1; i >= 0; i-- {
+		s := &statistics[i]
+		if len(s.Samples) == 0 {
+			continue
+		}
+		box, err := plotter.NewBoxPlot(vg.Points(10), float64(len(names)), plotter.Values(s.Samples))
+		if err != nil {
+			return err
+		}
+		box.Horizontal = true
+		c := modeColor(s.Mode)
+		box.BoxStyle.Color, box.MedianStyle.Color, box.WhiskerStyle.Color, box.GlyphStyle.Color = c, c, c, c
+		p.Add(box)
+		names = append(names, s.Name())
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	p.NominalY(names...)
+	return p.Save(8*vg.Inch, vg.Length(len(names))*vg.Inch/4+2*vg.Inch, file)
+}
+
+// savePNG writes an image to a png file
+func savePNG(file string, img *vgimg.Canvas) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	_, err = vgimg.PngCanvas{Canvas: img}.WriteTo(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Pool is a fixed number of workers that run tasks
+type Pool struct {
+	tasks chan func()
+	group sync.WaitGroup
+}
+
+// NewPool creates a pool of workers, the number of workers is GOMAXPROCS if it isn't positive
+func NewPool(workers int) *Pool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	pool := &Pool{
+		tasks: make(chan func()),
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range pool.tasks {
+				task()
+				pool.group.Done()
+			}
+		}()
+	}
+	return pool
+}
+
+// Submit queues a task, it blocks until a worker is free
+func (p *Pool) Submit(task func()) {
+	p.group.Add(1)
+	p.tasks <- task
+}
+
+// Wait waits for the submitted tasks to finish
+func (p *Pool) Wait() {
+	p.group.Wait()
+}
+
+// Close stops the workers after the submitted tasks finish
+func (p *Pool) Close() {
+	p.Wait()
+	close(p.tasks)
+}
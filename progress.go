@@ -0,0 +1,139 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Tally is the progress of a configuration
+type Tally struct {
+	Seeds     int
+	Converged int
+	// Epochs is the total epochs of the seeds that converged
+	Epochs int
+}
+
+// Progress reports the progress of repeated experiments
+// On a terminal it is a single line that is updated and otherwise it is a line logged every interval
+type Progress struct {
+	sync.Mutex
+	Writer   io.Writer
+	Terminal bool
+	Interval time.Duration
+	// Unit is the name of the training steps
+	Unit string
+	// Seeds is the number of seeds of each configuration
+	Seeds int
+	// Total is the number of runs of every configuration
+	Total          int
+	Done           int
+	Start, Last    time.Time
+	Configurations map[string]*Tally
+	// pending is set if the terminal line hasn't been ended
+	pending bool
+}
+
+// NewProgress creates a progress reporter for a number of configurations, it is nil if the writer is nil
+func NewProgress(writer io.Writer, unit string, seeds, configurations int) *Progress {
+	if writer == nil {
+		return nil
+	}
+	now := time.Now()
+	return &Progress{
+		Writer:         writer,
+		Terminal:       IsTerminal(writer),
+		Interval:       10 * time.Second,
+		Unit:           unit,
+		Seeds:          seeds,
+		Total:          seeds * configurations,
+		Start:          now,
+		Last:           now,
+		Configurations: make(map[string]*Tally),
+	}
+}
+
+// IsTerminal is true if the writer is a terminal
+func IsTerminal(writer io.Writer) bool {
+	file, ok := writer.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Update adds a finished seed to the progress of a configuration
+func (p *Progress) Update(name string, converged bool, epochs int) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	tally := p.Configurations[name]
+	if tally == nil {
+		tally = &Tally{}
+		p.Configurations[name] = tally
+	}
+	tally.Seeds++
+	if converged {
+		tally.Converged++
+		tally.Epochs += epochs
+	}
+	p.Done++
+
+	now := time.Now()
+	line := p.line(name, tally, now)
+	if tally.Seeds == p.Seeds {
+		// a finished configuration always gets a line of its own
+		if p.Terminal {
+			fmt.Fprintf(p.Writer, "\r%s\x1b[K\n", line)
+		} else {
+			fmt.Fprintln(p.Writer, line)
+		}
+		p.Last, p.pending = now, false
+	} else if p.Terminal {
+		fmt.Fprintf(p.Writer, "\r%s\x1b[K", line)
+		p.pending = true
+	} else if now.Sub(p.Last) >= p.Interval {
+		fmt.Fprintln(p.Writer, line)
+		p.Last = now
+	}
+}
+
+// Finish ends the terminal line
+func (p *Progress) Finish() {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	if p.pending {
+		fmt.Fprintln(p.Writer)
+		p.pending = false
+	}
+}
+
+// line is the progress of a configuration and the estimated time until every configuration is done
+func (p *Progress) line(name string, tally *Tally, now time.Time) string {
+	elapsed, left := now.Sub(p.Start), time.Duration(0)
+	if p.Done > 0 {
+		left = time.Duration(float64(elapsed) / float64(p.Done) * float64(p.Total-p.Done))
+	}
+	mean := 0.0
+	if tally.Converged > 0 {
+		mean = float64(tally.Epochs) / float64(tally.Converged)
+	}
+	return fmt.Sprintf("%s: %d/%d seeds %.1f%% converged %.1f %s, %d/%d runs %s elapsed %s left",
+		name, tally.Seeds, p.Seeds, 100*float64(tally.Converged)/float64(tally.Seeds), mean, p.Unit,
+		p.Done, p.Total, elapsed.Round(time.Second), left.Round(time.Second))
+}
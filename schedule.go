@@ -0,0 +1,253 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Schedule changes the learning rate over the course of training
+type Schedule interface {
+	// Rate is the learning rate multiplier for an epoch given the costs of the previous epochs
+	Rate(epoch int, costs []float32) float32
+}
+
+// ScheduleType a learning rate schedule type
+type ScheduleType int
+
+const (
+	// ScheduleConstant is a constant learning rate
+	ScheduleConstant ScheduleType = iota
+	// ScheduleWarmup is a constant learning rate after a linear warmup
+	ScheduleWarmup
+	// ScheduleStep decays the learning rate by gamma every step size epochs
+	ScheduleStep
+	// ScheduleExponential decays the learning rate continuously by gamma every step size epochs
+	ScheduleExponential
+	// ScheduleCosine is cosine annealing with warm restarts
+	ScheduleCosine
+	// ScheduleOneCycle is the one cycle learning rate policy
+	ScheduleOneCycle
+	// SchedulePlateau decays the learning rate by gamma when the cost stops improving
+	SchedulePlateau
+)
+
+// Schedules the schedules
+var Schedules = [...]ScheduleType{
+	ScheduleConstant,
+	ScheduleWarmup,
+	ScheduleStep,
+	ScheduleExponential,
+	ScheduleCosine,
+	ScheduleOneCycle,
+	SchedulePlateau,
+}
+
+// Converts the schedule to a string
+func (s ScheduleType) String() string {
+	switch s {
+	case ScheduleConstant:
+		return "constant"
+	case ScheduleWarmup:
+		return "warmup"
+	case ScheduleStep:
+		return "step"
+	case ScheduleExponential:
+		return "exponential"
+	case ScheduleCosine:
+		return "cosine"
+	case ScheduleOneCycle:
+		return "onecycle"
+	case SchedulePlateau:
+		return "plateau"
+	}
+	return "unknown"
+}
+
+// Set sets the schedule from its name
+func (s *ScheduleType) Set(name string) error {
+	for _, schedule := range Schedules {
+		if schedule.String() == name {
+			*s = schedule
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown schedule %s", name)
+}
+
+// MarshalText converts the schedule to its name
+func (s ScheduleType) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText sets the schedule from its name
+func (s *ScheduleType) UnmarshalText(text []byte) error {
+	return s.Set(string(text))
+}
+
+// Check checks the hyperparameters of a schedule of this type
+func (s ScheduleType) Check(h Hyperparameters) error {
+	if h.Warmup < 0 {
+		return fmt.Errorf("the warmup of the %s schedule should not be negative", s)
+	}
+	switch s {
+	case ScheduleWarmup:
+		if h.Warmup <= 0 {
+			return fmt.Errorf("the warmup of the %s schedule should be positive", s)
+		}
+	case ScheduleStep, ScheduleExponential:
+		if h.StepSize <= 0 {
+			return fmt.Errorf("the step size of the %s schedule should be positive", s)
+		}
+	case SchedulePlateau:
+		if h.Patience <= 0 {
+			return fmt.Errorf("the patience of the %s schedule should be positive", s)
+		}
+	case ScheduleCosine:
+		if h.Period <= 0 {
+			return fmt.Errorf("the period of the %s schedule should be positive", s)
+		}
+		if h.PeriodMult < 1 {
+			return fmt.Errorf("the period mult of the %s schedule should be at least 1", s)
+		}
+	}
+	// the decaying schedules multiply the learning rate by gamma
+	if (s == ScheduleStep || s == ScheduleExponential || s == SchedulePlateau) && (h.Gamma <= 0 || h.Gamma > 1) {
+		return fmt.Errorf("the gamma of the %s schedule should be above 0 and at most 1", s)
+	}
+	return nil
+}
+
+// New creates a schedule of this type for training that lasts epochs
+// It panics if the hyperparameters don't pass Check
+func (s ScheduleType) New(h Hyperparameters, epochs int) Schedule {
+	err := s.Check(h)
+	if err != nil {
+		panic(err)
+	}
+	var schedule Schedule
+	switch s {
+	case ScheduleConstant, ScheduleWarmup:
+		schedule = Constant{}
+	case ScheduleStep:
+		schedule = Step{Hyperparameters: h}
+	case ScheduleExponential:
+		schedule = Exponential{Hyperparameters: h}
+	case ScheduleCosine:
+		schedule = Cosine{Hyperparameters: h}
+	case ScheduleOneCycle:
+		schedule = OneCycle{Hyperparameters: h, Epochs: epochs}
+	case SchedulePlateau:
+		schedule = &Plateau{Hyperparameters: h, Best: float32(math.Inf(1)), Factor: 1}
+	default:
+		panic("unknown schedule")
+	}
+	if h.Warmup > 0 {
+		schedule = Warmup{Schedule: schedule, Epochs: h.Warmup}
+	}
+	return schedule
+}
+
+// Constant is a constant learning rate
+type Constant struct{}
+
+// Rate is the learning rate multiplier
+func (Constant) Rate(epoch int, costs []float32) float32 {
+	return 1
+}
+
+// Warmup linearly increases the learning rate of a schedule over the first epochs
+type Warmup struct {
+	Schedule
+	Epochs int
+}
+
+// Rate is the learning rate multiplier
+func (w Warmup) Rate(epoch int, costs []float32) float32 {
+	rate := w.Schedule.Rate(epoch, costs)
+	if epoch < w.Epochs {
+		rate *= float32(epoch+1) / float32(w.Epochs)
+	}
+	return rate
+}
+
+// Step decays the learning rate by gamma every step size epochs
+type Step struct {
+	Hyperparameters
+}
+
+// Rate is the learning rate multiplier
+func (s Step) Rate(epoch int, costs []float32) float32 {
+	return pow(s.Gamma, float32(epoch/s.StepSize))
+}
+
+// Exponential decays the learning rate continuously by gamma every step size epochs
+type Exponential struct {
+	Hyperparameters
+}
+
+// Rate is the learning rate multiplier
+func (e Exponential) Rate(epoch int, costs []float32) float32 {
+	return pow(e.Gamma, float32(epoch)/float32(e.StepSize))
+}
+
+// Cosine is cosine annealing with warm restarts
+// https://arxiv.org/abs/1608.03983
+type Cosine struct {
+	Hyperparameters
+}
+
+// Rate is the learning rate multiplier
+func (c Cosine) Rate(epoch int, costs []float32) float32 {
+	period := c.Period
+	for epoch >= period {
+		epoch -= period
+		period *= c.PeriodMult
+	}
+	return c.MinRate + (1-c.MinRate)*(1+cos(math.Pi*float32(epoch)/float32(period)))/2
+}
+
+// OneCycle increases the learning rate for the first 30% of training then anneals it
+// https://arxiv.org/abs/1708.07120
+type OneCycle struct {
+	Hyperparameters
+	Epochs int
+}
+
+// Rate is the learning rate multiplier
+func (o OneCycle) Rate(epoch int, costs []float32) float32 {
+	peak := int(.3 * float32(o.Epochs))
+	if epoch < peak {
+		return o.MinRate + (1-o.MinRate)*float32(epoch)/float32(peak)
+	}
+	return o.MinRate + (1-o.MinRate)*(1+cos(math.Pi*float32(epoch-peak)/float32(o.Epochs-peak)))/2
+}
+
+// Plateau decays the learning rate by gamma when the cost has not improved for patience epochs
+type Plateau struct {
+	Hyperparameters
+	Best   float32
+	Wait   int
+	Seen   int
+	Factor float32
+}
+
+// Rate is the learning rate multiplier
+func (p *Plateau) Rate(epoch int, costs []float32) float32 {
+	for _, cost := range costs[p.Seen:] {
+		if cost < p.Best*(1-1e-4) {
+			p.Best, p.Wait = cost, 0
+			continue
+		}
+		p.Wait++
+		if p.Wait >= p.Patience {
+			p.Factor *= p.Gamma
+			p.Wait = 0
+		}
+	}
+	p.Seen = len(costs)
+	return p.Factor
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// MaxRequestSize is the largest predict request body in bytes
+const MaxRequestSize = 1 << 20
+
+// PredictRequest is a single input or a batch of inputs
+type PredictRequest struct {
+	Input  []float32   `json:"input,omitempty"`
+	Inputs [][]float32 `json:"inputs,omitempty"`
+}
+
+// Prediction is the outputs for each input
+// The classes and their labels are only predicted by classifiers,
+// and the probabilities only by classifiers with a softmax output
+type Prediction struct {
+	Outputs       [][]float32 `json:"outputs"`
+	Probabilities [][]float32 `json:"probabilities,omitempty"`
+	Classes       []int       `json:"classes,omitempty"`
+	Labels        []string    `json:"labels,omitempty"`
+}
+
+// ModelInfo is the metadata of a served model
+type ModelInfo struct {
+	Dataset    string        `json:"dataset"`
+	Mode       Mode          `json:"mode"`
+	Optimizer  OptimizerType `json:"optimizer"`
+	Seed       int64         `json:"seed"`
+	Epochs     int           `json:"epochs"`
+	Converged  bool          `json:"converged"`
+	Inputs     int           `json:"inputs"`
+	Outputs    int           `json:"outputs"`
+	Task       Task          `json:"task"`
+	Classifier bool          `json:"classifier"`
+	Classes    int           `json:"classes,omitempty"`
+	Labels     []string      `json:"labels,omitempty"`
+	// Activations are the activation functions of each layer, the last is the output activation
+	Activations Activations `json:"activations"`
+	// Layers are the shapes of the weight matrix and bias vector of each layer
+	Layers [][]int `json:"layers"`
+	Config Config  `json:"config"`
+}
+
+// Server serves the predictions of a model over http
+type Server struct {
+	Model   *Model
+	Network *Network
+	mux     *http.ServeMux
+}
+
+// NewServer creates a server for a model with health, model and predict endpoints
+func NewServer(model *Model) (*Server, error) {
+	network, err := model.Network()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Model:   model,
+		Network: network,
+		mux:     http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/health", s.health)
+	s.mux.HandleFunc("/model", s.model)
+	s.mux.HandleFunc("/predict", s.predict)
+	return s, nil
+}
+
+// ServeHTTP routes a request to its endpoint
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// respond writes a json response
+func respond(w http.ResponseWriter, status int, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		status, data = http.StatusInternalServerError, []byte(fmt.Sprintf("{\"error\":%q}", err.Error()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+// fail writes a json error
+func fail(w http.ResponseWriter, status int, format string, a ...interface{}) {
+	respond(w, status, map[string]string{"error": fmt.Sprintf(format, a...)})
+}
+
+// health reports that the server is up
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		fail(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	respond(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// model reports the metadata of the model
+func (s *Server) model(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		fail(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	m := s.Model
+	info := ModelInfo{
+		Dataset:     m.Dataset,
+		Mode:        m.Mode,
+		Optimizer:   m.Optimizer,
+		Seed:        m.Seed,
+		Epochs:      m.Epochs,
+		Converged:   m.Converged,
+		Inputs:      m.In,
+		Outputs:     m.Out,
+		Task:        m.Task,
+		Classifier:  m.Classifier(),
+		Activations: m.Activations,
+		Config:      m.Config,
+	}
+	if info.Classifier {
+		info.Classes, info.Labels = len(m.Labels), m.Labels
+	}
+	for _, weight := range m.Weights {
+		info.Layers = append(info.Layers, weight.Shape)
+	}
+	respond(w, http.StatusOK, info)
+}
+
+// predict computes the outputs of the network for the inputs
+func (s *Server) predict(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		fail(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	var request PredictRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestSize)).Decode(&request)
+	if err != nil {
+		fail(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	inputs := request.Inputs
+	if request.Input != nil {
+		inputs = append([][]float32{request.Input}, inputs...)
+	}
+	if len(inputs) == 0 {
+		fail(w, http.StatusBadRequest, "there should be an input or inputs")
+		return
+	}
+	data := make([]Datum, len(inputs))
+	for i, input := range inputs {
+		if len(input) != s.Network.In {
+			fail(w, http.StatusBadRequest, "input %d has %d values and should have %d", i, len(input), s.Network.In)
+			return
+		}
+		data[i] = Datum{Input: input, Output: make([]float32, s.Network.Out)}
+	}
+
+	_, outputs := s.Network.Evaluate(data)
+	out, classifier := s.Network.Out, s.Model.Classifier()
+	// the outputs of the softmax are the probabilities
+	probabilities := classifier && s.Model.Output() == ActivationSoftmax
+	prediction := Prediction{
+		Outputs: make([][]float32, len(data)),
+	}
+	for i := range data {
+		prediction.Outputs[i] = outputs[i*out : (i+1)*out]
+		if probabilities {
+			prediction.Probabilities = append(prediction.Probabilities, prediction.Outputs[i])
+		}
+		if classifier {
+			class := s.Network.Dataset.Label(prediction.Outputs[i])
+			prediction.Classes = append(prediction.Classes, class)
+			prediction.Labels = append(prediction.Labels, s.Model.Labels[class])
+		}
+	}
+	respond(w, http.StatusOK, prediction)
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 The Inception Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Mean is the mean of the values, 0 if there are none
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+// StdDev is the sample standard deviation of the values, 0 if there are less than 2
+func StdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mean, sum := Mean(values), 0.0
+	for _, value := range values {
+		sum += (value - mean) * (value - mean)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
+
+// Percentile is the pth percentile of the values with linear interpolation, 0 if there are none
+func Percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	fraction := rank - float64(lower)
+	return sorted[lower] + fraction*(sorted[lower+1]-sorted[lower])
+}
+
+// Median is the median of the values
+func Median(values []float64) float64 {
+	return Percentile(values, 50)
+}
+
+// Bootstrap is the percentile bootstrap confidence interval of the mean of the values
+func Bootstrap(rnd *rand.Rand, values []float64, resamples int, confidence float64) (lower, upper float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	means, sample := make([]float64, resamples), make([]float64, len(values))
+	for i := range means {
+		for j := range sample {
+			sample[j] = values[rnd.Intn(len(values))]
+		}
+		means[i] = Mean(sample)
+	}
+	alpha := (1 - confidence) / 2 * 100
+	return Percentile(means, alpha), Percentile(means, 100-alpha)
+}
+
+// Wilcoxon is the two sided wilcoxon signed rank test of paired values
+// The pairs with no difference are dropped and the p value uses the normal approximation with tie and continuity corrections
+// w is the smaller of the sums of the positive and negative ranks and n is the number of pairs with a difference
+func Wilcoxon(a, b []float64) (w, p float64, n int) {
+	type Difference struct {
+		Abs  float64
+		Sign float64
+		Rank float64
+	}
+	differences := []Difference{}
+	for i := range a {
+		d := a[i] - b[i]
+		if d == 0 {
+			continue
+		}
+		differences = append(differences, Difference{Abs: math.Abs(d), Sign: math.Copysign(1, d)})
+	}
+	n = len(differences)
+	if n == 0 {
+		return 0, 1, 0
+	}
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].Abs < differences[j].Abs
+	})
+	ties := 0.0
+	for i := 0; i < n; {
+		j := i
+		for j < n && differences[j].Abs == differences[i].Abs {
+			j++
+		}
+		// tied differences share the average of their ranks
+		rank, t := float64(i+j+1)/2, float64(j-i)
+		for k := i; k < j; k++ {
+			differences[k].Rank = rank
+		}
+		ties += t*t*t - t
+		i = j
+	}
+	positive, negative := 0.0, 0.0
+	for _, difference := range differences {
+		if difference.Sign > 0 {
+			positive += difference.Rank
+		} else {
+			negative += difference.Rank
+		}
+	}
+	w = math.Min(positive, negative)
+	size := float64(n)
+	mean := size * (size + 1) / 4
+	deviation := math.Sqrt(size*(size+1)*(2*size+1)/24 - ties/48)
+	if deviation == 0 {
+		return w, 1, n
+	}
+	z := (math.Abs(w-mean) - .5) / deviation
+	if z < 0 {
+		z = 0
+	}
+	p = math.Erfc(z / math.Sqrt2)
+	return w, p, n
+}